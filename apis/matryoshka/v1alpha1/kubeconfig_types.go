@@ -0,0 +1,405 @@
+// Copyright 2021 OnMetal authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultAuthInfoClientCertificateKey is the Secret key resolveAuthInfo reads an AuthInfo's
+	// client certificate from when ClientCertificate.Secret.Key is unset.
+	DefaultAuthInfoClientCertificateKey = "tls.crt"
+	// DefaultAuthInfoClientKeyKey is the Secret key resolveAuthInfo reads an AuthInfo's client key
+	// from when ClientKey.Secret.Key is unset.
+	DefaultAuthInfoClientKeyKey = "tls.key"
+	// DefaultAuthInfoTokenKey is the Secret key resolveAuthInfo reads an AuthInfo's bearer token
+	// from when Token.Secret.Key is unset.
+	DefaultAuthInfoTokenKey = "token"
+	// DefaultAuthInfoPasswordKey is the Secret key resolveAuthInfo reads an AuthInfo's password
+	// from when Password.Secret.Key is unset.
+	DefaultAuthInfoPasswordKey = "password"
+	// DefaultClusterCertificateAuthorityKey is the Secret key resolveCluster reads a Cluster's CA
+	// certificate from when CertificateAuthority.Secret.Key is unset.
+	DefaultClusterCertificateAuthorityKey = "ca.crt"
+	// DefaultOIDCClientSecretKey is the Secret key resolveOIDCTokenSource reads an
+	// OIDCTokenSource's OAuth2 client secret from when ClientSecret.Secret.Key is unset.
+	DefaultOIDCClientSecretKey = "client-secret"
+	// DefaultOIDCRefreshTokenKey is the Secret key resolveOIDCTokenSource reads and writes an
+	// OIDCTokenSource's refresh token to when RefreshTokenSecret.Key is unset.
+	DefaultOIDCRefreshTokenKey = "refresh-token"
+	// DefaultOIDCIDTokenKey is the Secret key resolveOIDCTokenSource caches the most recently
+	// minted id_token under.
+	DefaultOIDCIDTokenKey = "id-token"
+	// DefaultOIDCIDTokenExpiryKey is the Secret key resolveOIDCTokenSource caches the cached
+	// id_token's expiry (RFC 3339) under, so a later Resolve can skip the refresh-token exchange
+	// while the cached id_token is still valid.
+	DefaultOIDCIDTokenExpiryKey = "id-token-expiry"
+	// DefaultKubeconfigOutputKey is the Secret key EncodeOutput writes the yaml/json/exec-credential
+	// encoders' output under when KubeconfigOutput.Key is unset.
+	DefaultKubeconfigOutputKey = "kubeconfig"
+)
+
+// KubeconfigOutputFormat names one of the Encoders ReconcileOutput can render a resolved
+// kubeconfig through.
+type KubeconfigOutputFormat string
+
+const (
+	// KubeconfigOutputFormatYAML renders a classic YAML kubeconfig under a single Secret key.
+	KubeconfigOutputFormatYAML KubeconfigOutputFormat = "YAML"
+	// KubeconfigOutputFormatJSON renders a JSON kubeconfig under a single Secret key.
+	KubeconfigOutputFormatJSON KubeconfigOutputFormat = "JSON"
+	// KubeconfigOutputFormatSplit drops the CA certificate of the first Cluster carrying one and
+	// the client certificate/key/token of the single AuthInfo carrying them into separate Secret
+	// keys, for consumers that mount individual PEM files instead of parsing a kubeconfig.
+	// Encoding fails if more than one AuthInfo carries such data.
+	KubeconfigOutputFormatSplit KubeconfigOutputFormat = "Split"
+	// KubeconfigOutputFormatExecCredential renders the first AuthInfo as an ExecCredential
+	// response, for use as the response body of an exec credential plugin HTTP endpoint.
+	KubeconfigOutputFormatExecCredential KubeconfigOutputFormat = "ExecCredential"
+)
+
+// KubeconfigOutput describes where and in which shapes a resolved kubeconfig should be written.
+type KubeconfigOutput struct {
+	// SecretRef names the Secret the rendered output is written to, in the Kubeconfig's own
+	// namespace. The Secret is created if it does not already exist.
+	SecretRef corev1.LocalObjectReference `json:"secretRef"`
+	// Key is the Secret key the yaml/json/exec-credential encoders write their output under.
+	// Defaults to DefaultKubeconfigOutputKey. Ignored by the split encoder, which always uses its
+	// own fixed per-field keys.
+	// +optional
+	Key string `json:"key,omitempty"`
+	// Formats are the output shapes to render, merged into a single Secret. Rendering the same
+	// Secret key through more than one format is not supported; the later format in the list wins.
+	Formats []KubeconfigOutputFormat `json:"formats"`
+}
+
+// SecretSelector selects a single key of a Secret in the Kubeconfig's own namespace.
+type SecretSelector struct {
+	// Name is the name of the Secret to select from.
+	Name string `json:"name"`
+	// Key is the data key to select. Defaults to a field-specific key if unset.
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// ConfigMapKeySelector selects a single key of a ConfigMap in the Kubeconfig's own namespace.
+type ConfigMapKeySelector struct {
+	// Name is the name of the ConfigMap to select from.
+	Name string `json:"name"`
+	// Key is the data key to select.
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// ClusterReference names another Kubeconfig in the same namespace whose resolved clientcmd Config
+// is used to build a client for a source cluster, instead of the controller's own cluster.
+type ClusterReference struct {
+	// Name is the name of the referenced Kubeconfig.
+	Name string `json:"name"`
+}
+
+// ClusterSecretSelector selects a single key of a Secret, optionally on another cluster (resolved
+// via ClusterRef) and/or in another namespace than the Kubeconfig it was declared in.
+type ClusterSecretSelector struct {
+	SecretSelector `json:",inline"`
+	// ClusterRef, if set, resolves the Secret against the cluster the referenced Kubeconfig
+	// describes instead of the controller's own cluster.
+	// +optional
+	ClusterRef *ClusterReference `json:"clusterRef,omitempty"`
+	// Namespace overrides the namespace the Secret is looked up in. Defaults to the Kubeconfig's
+	// own namespace.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// SecretValueSource sources a single resolved value (certificate, key, token, password, ...) from
+// a Secret, optionally on another cluster.
+type SecretValueSource struct {
+	// Secret selects the Secret and key holding the value.
+	Secret *ClusterSecretSelector `json:"secret,omitempty"`
+}
+
+// NamedAuthInfo relates a clientcmd-style named AuthInfo entry to its definition.
+type NamedAuthInfo struct {
+	// Name is the AuthInfo's name in the resolved kubeconfig.
+	Name string `json:"name"`
+	// AuthInfo is the AuthInfo definition.
+	AuthInfo AuthInfo `json:"authInfo"`
+}
+
+// AuthInfo is the matryoshka equivalent of clientcmdapi.AuthInfo: it describes how to resolve the
+// credentials for a single kubeconfig user, sourcing each field from a Secret instead of embedding
+// it directly.
+type AuthInfo struct {
+	// ClientCertificate sources AuthInfo.ClientCertificateData.
+	// +optional
+	ClientCertificate *SecretValueSource `json:"clientCertificate,omitempty"`
+	// ClientKey sources AuthInfo.ClientKeyData.
+	// +optional
+	ClientKey *SecretValueSource `json:"clientKey,omitempty"`
+	// Token sources AuthInfo.Token.
+	// +optional
+	Token *SecretValueSource `json:"token,omitempty"`
+	// Password sources AuthInfo.Password.
+	// +optional
+	Password *SecretValueSource `json:"password,omitempty"`
+	// Username is copied verbatim into AuthInfo.Username.
+	// +optional
+	Username string `json:"username,omitempty"`
+	// Impersonate is copied verbatim into AuthInfo.Impersonate.
+	// +optional
+	Impersonate string `json:"impersonate,omitempty"`
+	// ImpersonateGroups is copied verbatim into AuthInfo.ImpersonateGroups.
+	// +optional
+	ImpersonateGroups []string `json:"impersonateGroups,omitempty"`
+	// Exec, if set, resolves to an AuthInfo.Exec exec-plugin configuration.
+	// +optional
+	Exec *ExecConfig `json:"exec,omitempty"`
+	// AuthProvider, if set, is copied into AuthInfo.AuthProvider as-is, for legacy auth-provider
+	// entries whose config does not need resolving from a Secret (e.g. gcp).
+	// +optional
+	AuthProvider *AuthProviderConfig `json:"authProvider,omitempty"`
+	// OIDCTokenSource, if set, mints AuthInfo.AuthProvider by exchanging a refresh token against
+	// an OIDC issuer instead of reading a static token Secret.
+	// +optional
+	OIDCTokenSource *OIDCTokenSource `json:"oidcTokenSource,omitempty"`
+	// ClientCertificateRequest, if set, issues AuthInfo.ClientCertificateData/ClientKeyData via a
+	// CertificateSigningRequest instead of reading a static ClientCertificate/ClientKey Secret.
+	// +optional
+	ClientCertificateRequest *ClientCertificateRequest `json:"clientCertificateRequest,omitempty"`
+}
+
+// CertificateSubject is the subject of a ClientCertificateRequest's certificate signing request.
+type CertificateSubject struct {
+	// CommonName is the certificate's subject common name, conventionally the Kubernetes username.
+	CommonName string `json:"commonName"`
+	// Organizations are the certificate's subject organizations, conventionally the Kubernetes
+	// groups the issued identity should belong to.
+	// +optional
+	Organizations []string `json:"organizations,omitempty"`
+}
+
+// ClientCertificateRequest issues a client certificate/key pair via a
+// certificates.k8s.io/v1 CertificateSigningRequest, generating a private key and submitting a new
+// CSR on first use or when the current certificate is within RenewBefore of expiring.
+type ClientCertificateRequest struct {
+	// Secret is the Secret the issued certificate and private key are written to, under
+	// DefaultAuthInfoClientCertificateKey/DefaultAuthInfoClientKeyKey.
+	Secret *SecretSelector `json:"secret"`
+	// Subject is the requested certificate's subject.
+	Subject CertificateSubject `json:"subject"`
+	// SignerName is the CertificateSigningRequest's spec.signerName (e.g.
+	// "kubernetes.io/kube-apiserver-client").
+	SignerName string `json:"signerName"`
+	// Usages is the CertificateSigningRequest's spec.usages.
+	// +optional
+	Usages []certificatesv1.KeyUsage `json:"usages,omitempty"`
+	// ValidityDuration requests a certificate valid for the given duration, via the
+	// CertificateSigningRequest's spec.expirationSeconds. Signers are free to issue a
+	// shorter-lived certificate than requested.
+	// +optional
+	ValidityDuration *metav1.Duration `json:"validityDuration,omitempty"`
+	// RenewBefore is how long before the current certificate's NotAfter a new
+	// CertificateSigningRequest is submitted. Defaults to 24h.
+	// +optional
+	RenewBefore metav1.Duration `json:"renewBefore,omitempty"`
+	// AutoApprove, if true, approves the submitted CertificateSigningRequest directly (the
+	// controller's ServiceAccount needs RBAC to update certificatesigningrequests/approval for
+	// SignerName).
+	// +optional
+	AutoApprove bool `json:"autoApprove,omitempty"`
+}
+
+// OIDCClientSecretSource sources an OIDCTokenSource's OAuth2 client secret.
+type OIDCClientSecretSource struct {
+	// Secret selects the Secret and key holding the client secret.
+	Secret *SecretSelector `json:"secret,omitempty"`
+}
+
+// OIDCTokenSource mints an AuthInfo's AuthProvider by performing an OAuth2 refresh-token exchange
+// against an OIDC issuer, caching the resulting id_token/refresh_token back into
+// RefreshTokenSecret, in the shape kubectl's built-in "oidc" auth-provider expects.
+type OIDCTokenSource struct {
+	// IssuerURL is the OIDC issuer's base URL; the token endpoint is resolved as
+	// "<IssuerURL>/token".
+	IssuerURL string `json:"issuerURL"`
+	// ClientID is the OAuth2 client ID to exchange the refresh token with.
+	ClientID string `json:"clientID"`
+	// ClientSecret sources the OAuth2 client secret.
+	ClientSecret OIDCClientSecretSource `json:"clientSecret"`
+	// Scopes are the OAuth2 scopes requested on refresh.
+	// +optional
+	Scopes []string `json:"scopes,omitempty"`
+	// RefreshTokenSecret selects the Secret and key holding the refresh token. The same Secret is
+	// updated in place with the refreshed refresh token and the minted id_token/expiry.
+	RefreshTokenSecret *SecretSelector `json:"refreshTokenSecret"`
+}
+
+// AuthProviderConfig is the matryoshka equivalent of clientcmdapi.AuthProviderConfig.
+type AuthProviderConfig struct {
+	// Name is the auth-provider plugin name (e.g. "gcp", "oidc").
+	Name string `json:"name"`
+	// Config is the auth-provider's free-form configuration.
+	// +optional
+	Config map[string]string `json:"config,omitempty"`
+}
+
+// ExecConfig is the matryoshka equivalent of clientcmdapi.ExecConfig: it describes an exec
+// credential plugin invocation, sourcing environment variable values from Secrets/ConfigMaps
+// instead of embedding them directly.
+type ExecConfig struct {
+	// Command is the exec plugin binary to run.
+	Command string `json:"command"`
+	// Args are the arguments passed to Command.
+	// +optional
+	Args []string `json:"args,omitempty"`
+	// Env are environment variables to set when invoking Command.
+	// +optional
+	Env []ExecEnvVar `json:"env,omitempty"`
+	// APIVersion is the preferred input/output version for the plugin.
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty"`
+	// InstallHint is printed to the user when the plugin can't be found.
+	// +optional
+	InstallHint string `json:"installHint,omitempty"`
+	// ProvideClusterInfo instructs clientcmd to pass cluster information to the plugin.
+	// +optional
+	ProvideClusterInfo bool `json:"provideClusterInfo,omitempty"`
+	// InteractiveMode controls whether the plugin can use stdin. One of "Never", "IfAvailable",
+	// "Always".
+	// +optional
+	InteractiveMode string `json:"interactiveMode,omitempty"`
+}
+
+// ExecEnvVar is the matryoshka equivalent of clientcmdapi.ExecEnvVar, with an additional
+// ValueFrom to source the value from a Secret or ConfigMap.
+type ExecEnvVar struct {
+	// Name is the environment variable name.
+	Name string `json:"name"`
+	// Value is a literal value. Ignored if ValueFrom is set.
+	// +optional
+	Value string `json:"value,omitempty"`
+	// ValueFrom sources Value from a Secret or ConfigMap key instead of a literal.
+	// +optional
+	ValueFrom *ExecEnvVarSource `json:"valueFrom,omitempty"`
+}
+
+// ExecEnvVarSource sources an ExecEnvVar's value from a Secret or ConfigMap key.
+type ExecEnvVarSource struct {
+	// SecretKeyRef sources the value from a Secret key. SecretKeyRef.Key has no default; resolution
+	// fails if it is unset.
+	// +optional
+	SecretKeyRef *SecretSelector `json:"secretKeyRef,omitempty"`
+	// ConfigMapKeyRef sources the value from a ConfigMap key.
+	// +optional
+	ConfigMapKeyRef *ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+}
+
+// NamedCluster relates a clientcmd-style named Cluster entry to its definition.
+type NamedCluster struct {
+	// Name is the Cluster's name in the resolved kubeconfig.
+	Name string `json:"name"`
+	// Cluster is the Cluster definition.
+	Cluster Cluster `json:"cluster"`
+}
+
+// Cluster is the matryoshka equivalent of clientcmdapi.Cluster.
+type Cluster struct {
+	// Server is the address of the cluster's API server.
+	Server string `json:"server"`
+	// TLSServerName overrides the server name used to verify the cluster's certificate.
+	// +optional
+	TLSServerName string `json:"tlsServerName,omitempty"`
+	// InsecureSkipTLSVerify skips the cluster certificate verification.
+	// +optional
+	InsecureSkipTLSVerify bool `json:"insecureSkipTLSVerify,omitempty"`
+	// CertificateAuthority sources Cluster.CertificateAuthorityData.
+	// +optional
+	CertificateAuthority *SecretValueSource `json:"certificateAuthority,omitempty"`
+	// ProxyURL is copied verbatim into Cluster.ProxyURL.
+	// +optional
+	ProxyURL string `json:"proxyURL,omitempty"`
+}
+
+// NamedContext relates a clientcmd-style named Context entry to its definition.
+type NamedContext struct {
+	// Name is the Context's name in the resolved kubeconfig.
+	Name string `json:"name"`
+	// Context is the Context definition.
+	Context Context `json:"context"`
+}
+
+// Context is the matryoshka equivalent of clientcmdapi.Context.
+type Context struct {
+	// Cluster is the name of the NamedCluster this context refers to.
+	Cluster string `json:"cluster"`
+	// AuthInfo is the name of the NamedAuthInfo this context refers to.
+	AuthInfo string `json:"authInfo"`
+	// Namespace is the default namespace to use with this context.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// KubeconfigSpec defines the desired state of a Kubeconfig.
+type KubeconfigSpec struct {
+	// AuthInfos are the users the resolved kubeconfig should contain.
+	// +optional
+	AuthInfos []NamedAuthInfo `json:"authInfos,omitempty"`
+	// Clusters are the clusters the resolved kubeconfig should contain.
+	// +optional
+	Clusters []NamedCluster `json:"clusters,omitempty"`
+	// Contexts are the contexts the resolved kubeconfig should contain.
+	// +optional
+	Contexts []NamedContext `json:"contexts,omitempty"`
+	// CurrentContext is the name of the context the resolved kubeconfig should default to.
+	// +optional
+	CurrentContext string `json:"currentContext,omitempty"`
+	// Output, if set, has the controller render and reconcile the resolved kubeconfig into a
+	// Secret.
+	// +optional
+	Output *KubeconfigOutput `json:"output,omitempty"`
+}
+
+// KubeconfigStatus defines the observed state of a Kubeconfig.
+type KubeconfigStatus struct{}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// Kubeconfig resolves a kubectl-compatible kubeconfig from Secrets holding its constituent
+// credentials and certificate authority data.
+type Kubeconfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KubeconfigSpec   `json:"spec,omitempty"`
+	Status KubeconfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KubeconfigList contains a list of Kubeconfig.
+type KubeconfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Kubeconfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Kubeconfig{}, &KubeconfigList{})
+}
@@ -0,0 +1,169 @@
+// Copyright 2021 OnMetal authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubeconfig
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	matryoshkav1alpha1 "github.com/onmetal/matryoshka/apis/matryoshka/v1alpha1"
+	"github.com/onmetal/matryoshka/pkg/memorystore"
+	"github.com/onmetal/matryoshka/pkg/utils"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// defaultClusterClientTTL is how long a client built for a ClusterSecretSelector's ClusterRef is
+// cached before the referenced Kubeconfig is re-resolved.
+const defaultClusterClientTTL = 5 * time.Minute
+
+// clusterClientCache lazily builds and caches a client.Client per referenced Cluster-style
+// Kubeconfig, so a single Resolve only resolves and dials each remote cluster once.
+type clusterClientCache struct {
+	resolver *Resolver
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]clusterClientCacheEntry
+}
+
+type clusterClientCacheEntry struct {
+	client    client.Client
+	expiresAt time.Time
+}
+
+func newClusterClientCache(r *Resolver) *clusterClientCache {
+	ttl := r.clusterClientTTL
+	if ttl == 0 {
+		ttl = defaultClusterClientTTL
+	}
+
+	return &clusterClientCache{
+		resolver: r,
+		ttl:      ttl,
+		entries:  map[string]clusterClientCacheEntry{},
+	}
+}
+
+// get returns a client.Client for the Kubeconfig named ref.Name in namespace, resolving and
+// building it on first use and reusing it until the cache entry's TTL expires.
+func (c *clusterClientCache) get(ctx context.Context, namespace string, ref *matryoshkav1alpha1.ClusterReference) (client.Client, error) {
+	key := namespace + "/" + ref.Name
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.client, nil
+	}
+
+	cl, err := c.build(ctx, namespace, ref.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = clusterClientCacheEntry{client: cl, expiresAt: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+
+	return cl, nil
+}
+
+func (c *clusterClientCache) build(ctx context.Context, namespace, name string) (client.Client, error) {
+	source := &matryoshkav1alpha1.Kubeconfig{}
+	if err := c.resolver.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, source); err != nil {
+		return nil, fmt.Errorf("error getting cluster reference kubeconfig %q: %w", name, err)
+	}
+
+	cfg, err := c.resolver.Resolve(ctx, source)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving cluster reference kubeconfig %q: %w", name, err)
+	}
+
+	rawKubeconfig, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling cluster reference kubeconfig %q: %w", name, err)
+	}
+
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig(rawKubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("error building rest config for cluster reference %q: %w", name, err)
+	}
+
+	cl, err := client.New(restConfig, client.Options{Scheme: c.resolver.scheme})
+	if err != nil {
+		return nil, fmt.Errorf("error building client for cluster reference %q: %w", name, err)
+	}
+
+	return cl, nil
+}
+
+// ReferenceSet tracks the objects a Kubeconfig refers to, split into the local store (resolved
+// against the controller's own cluster) and one store per remote ClusterRef.
+type ReferenceSet struct {
+	scheme *runtime.Scheme
+	local  *memorystore.Store
+	remote map[string]*memorystore.Store
+}
+
+func newReferenceSet(scheme *runtime.Scheme) *ReferenceSet {
+	return &ReferenceSet{
+		scheme: scheme,
+		local:  memorystore.New(scheme),
+		remote: map[string]*memorystore.Store{},
+	}
+}
+
+// storeFor returns the store that should hold references for the given ClusterRef, creating a
+// fresh per-cluster store on first use. A nil ref resolves to the local store.
+func (rs *ReferenceSet) storeFor(ref *matryoshkav1alpha1.ClusterReference) *memorystore.Store {
+	if ref == nil {
+		return rs.local
+	}
+
+	s, ok := rs.remote[ref.Name]
+	if !ok {
+		s = memorystore.New(rs.scheme)
+		rs.remote[ref.Name] = s
+	}
+
+	return s
+}
+
+// namespaceFor resolves the effective namespace for a ClusterSecretSelector: its own Namespace
+// override if set, otherwise the namespace of the Kubeconfig it was declared in.
+func namespaceFor(selector *matryoshkav1alpha1.ClusterSecretSelector, defaultNamespace string) string {
+	if selector.Namespace != "" {
+		return selector.Namespace
+	}
+
+	return defaultNamespace
+}
+
+// getClusterSecretSelector resolves selector against the store matching its ClusterRef (local or
+// remote), under its effective namespace.
+func getClusterSecretSelector(
+	ctx context.Context,
+	rs *ReferenceSet,
+	namespace string,
+	selector *matryoshkav1alpha1.ClusterSecretSelector,
+	defaultKey string,
+) ([]byte, error) {
+	return utils.GetSecretSelector(ctx, rs.storeFor(selector.ClusterRef), namespaceFor(selector, namespace), selector.SecretSelector, defaultKey)
+}
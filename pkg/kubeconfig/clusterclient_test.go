@@ -0,0 +1,86 @@
+// Copyright 2021 OnMetal authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubeconfig
+
+import (
+	"context"
+	"testing"
+
+	matryoshkav1alpha1 "github.com/onmetal/matryoshka/apis/matryoshka/v1alpha1"
+)
+
+func TestNamespaceFor(t *testing.T) {
+	cases := []struct {
+		name             string
+		selector         *matryoshkav1alpha1.ClusterSecretSelector
+		defaultNamespace string
+		want             string
+	}{
+		{
+			name:             "no override",
+			selector:         &matryoshkav1alpha1.ClusterSecretSelector{},
+			defaultNamespace: "kubeconfig-ns",
+			want:             "kubeconfig-ns",
+		},
+		{
+			name:             "override",
+			selector:         &matryoshkav1alpha1.ClusterSecretSelector{Namespace: "other-ns"},
+			defaultNamespace: "kubeconfig-ns",
+			want:             "other-ns",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := namespaceFor(tc.selector, tc.defaultNamespace); got != tc.want {
+				t.Errorf("namespaceFor() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWithResolvingDetectsCycles(t *testing.T) {
+	ctx, err := withResolving(context.Background(), "ns/a")
+	if err != nil {
+		t.Fatalf("withResolving() error = %v", err)
+	}
+
+	ctx, err = withResolving(ctx, "ns/b")
+	if err != nil {
+		t.Fatalf("withResolving() error = %v", err)
+	}
+
+	if _, err := withResolving(ctx, "ns/a"); err == nil {
+		t.Fatal("withResolving() should error when re-entering a Kubeconfig already being resolved")
+	}
+
+	if _, err := withResolving(ctx, "ns/c"); err != nil {
+		t.Errorf("withResolving() unexpected error for a fresh key: %v", err)
+	}
+}
+
+func TestWithResolvingAllowsIndependentBranches(t *testing.T) {
+	base, err := withResolving(context.Background(), "ns/a")
+	if err != nil {
+		t.Fatalf("withResolving() error = %v", err)
+	}
+
+	if _, err := withResolving(base, "ns/b"); err != nil {
+		t.Errorf("withResolving() unexpected error on first branch: %v", err)
+	}
+	if _, err := withResolving(base, "ns/c"); err != nil {
+		t.Errorf("withResolving() unexpected error on second branch: %v", err)
+	}
+}
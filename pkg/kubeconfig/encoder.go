@@ -0,0 +1,179 @@
+// Copyright 2021 OnMetal authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubeconfig
+
+import (
+	"encoding/json"
+	"fmt"
+
+	matryoshkav1alpha1 "github.com/onmetal/matryoshka/apis/matryoshka/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientauthenticationv1beta1 "k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
+	clientcmdapiv1 "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Encoder renders a resolved kubeconfig into one or more Secret data entries.
+type Encoder interface {
+	// Encode renders cfg and returns the Secret keys/values it contributes.
+	Encode(cfg *clientcmdapiv1.Config) (map[string][]byte, error)
+}
+
+// NewEncoder returns the Encoder registered for the given output format.
+func NewEncoder(format matryoshkav1alpha1.KubeconfigOutputFormat, key string) (Encoder, error) {
+	switch format {
+	case matryoshkav1alpha1.KubeconfigOutputFormatYAML:
+		return &yamlEncoder{key: key}, nil
+	case matryoshkav1alpha1.KubeconfigOutputFormatJSON:
+		return &jsonEncoder{key: key}, nil
+	case matryoshkav1alpha1.KubeconfigOutputFormatSplit:
+		return &splitEncoder{}, nil
+	case matryoshkav1alpha1.KubeconfigOutputFormatExecCredential:
+		return &execCredentialEncoder{key: key}, nil
+	default:
+		return nil, fmt.Errorf("unknown kubeconfig output format %q", format)
+	}
+}
+
+// yamlEncoder renders cfg as a classic YAML kubeconfig under a single Secret key.
+type yamlEncoder struct {
+	key string
+}
+
+func (e *yamlEncoder) Encode(cfg *clientcmdapiv1.Config) (map[string][]byte, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling kubeconfig to yaml: %w", err)
+	}
+
+	return map[string][]byte{e.key: data}, nil
+}
+
+// jsonEncoder renders cfg as a JSON kubeconfig under a single Secret key.
+type jsonEncoder struct {
+	key string
+}
+
+func (e *jsonEncoder) Encode(cfg *clientcmdapiv1.Config) (map[string][]byte, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling kubeconfig to json: %w", err)
+	}
+
+	return map[string][]byte{e.key: data}, nil
+}
+
+// splitEncoder drops the CA certificate and, for the single AuthInfo carrying them, the client
+// certificate/key/token into separate Secret keys, for consumers that mount individual PEM files
+// instead of parsing a kubeconfig. It only supports a single AuthInfo carrying that data: writing
+// more than one into the same fixed keys would silently clobber all but the last, so Encode
+// errors out instead.
+type splitEncoder struct{}
+
+func (e *splitEncoder) Encode(cfg *clientcmdapiv1.Config) (map[string][]byte, error) {
+	data := map[string][]byte{}
+
+	for _, cluster := range cfg.Clusters {
+		if len(cluster.Cluster.CertificateAuthorityData) > 0 {
+			data[matryoshkav1alpha1.DefaultClusterCertificateAuthorityKey] = cluster.Cluster.CertificateAuthorityData
+			break
+		}
+	}
+
+	seen := false
+	for _, authInfo := range cfg.AuthInfos {
+		if len(authInfo.AuthInfo.ClientCertificateData) == 0 && len(authInfo.AuthInfo.ClientKeyData) == 0 && authInfo.AuthInfo.Token == "" {
+			continue
+		}
+
+		if seen {
+			return nil, fmt.Errorf("kubeconfig output format %q only supports a single auth info carrying client certificate/key/token data, got at least two (%q)", matryoshkav1alpha1.KubeconfigOutputFormatSplit, authInfo.Name)
+		}
+		seen = true
+
+		if len(authInfo.AuthInfo.ClientCertificateData) > 0 {
+			data[matryoshkav1alpha1.DefaultAuthInfoClientCertificateKey] = authInfo.AuthInfo.ClientCertificateData
+		}
+		if len(authInfo.AuthInfo.ClientKeyData) > 0 {
+			data[matryoshkav1alpha1.DefaultAuthInfoClientKeyKey] = authInfo.AuthInfo.ClientKeyData
+		}
+		if authInfo.AuthInfo.Token != "" {
+			data[matryoshkav1alpha1.DefaultAuthInfoTokenKey] = []byte(authInfo.AuthInfo.Token)
+		}
+	}
+
+	return data, nil
+}
+
+// execCredentialEncoder renders cfg's first AuthInfo as an ExecCredential response, for use as the
+// response body of an exec credential plugin HTTP endpoint.
+type execCredentialEncoder struct {
+	key string
+}
+
+func (e *execCredentialEncoder) Encode(cfg *clientcmdapiv1.Config) (map[string][]byte, error) {
+	if len(cfg.AuthInfos) == 0 {
+		return nil, fmt.Errorf("kubeconfig has no auth infos to encode as an exec credential")
+	}
+
+	authInfo := cfg.AuthInfos[0].AuthInfo
+
+	cred := &clientauthenticationv1beta1.ExecCredential{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "client.authentication.k8s.io/v1beta1",
+			Kind:       "ExecCredential",
+		},
+		Status: &clientauthenticationv1beta1.ExecCredentialStatus{
+			Token:                 authInfo.Token,
+			ClientCertificateData: string(authInfo.ClientCertificateData),
+			ClientKeyData:         string(authInfo.ClientKeyData),
+		},
+	}
+
+	data, err := json.Marshal(cred)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling exec credential: %w", err)
+	}
+
+	return map[string][]byte{e.key: data}, nil
+}
+
+// EncodeOutput renders cfg through every format configured on output, merging the resulting Secret
+// keys into a single data map suitable for writing to output.SecretRef.
+func EncodeOutput(cfg *clientcmdapiv1.Config, output *matryoshkav1alpha1.KubeconfigOutput) (map[string][]byte, error) {
+	key := output.Key
+	if key == "" {
+		key = matryoshkav1alpha1.DefaultKubeconfigOutputKey
+	}
+
+	data := map[string][]byte{}
+	for _, format := range output.Formats {
+		encoder, err := NewEncoder(format, key)
+		if err != nil {
+			return nil, err
+		}
+
+		encoded, err := encoder.Encode(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding kubeconfig as %q: %w", format, err)
+		}
+
+		for k, v := range encoded {
+			data[k] = v
+		}
+	}
+
+	return data, nil
+}
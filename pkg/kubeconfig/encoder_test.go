@@ -0,0 +1,148 @@
+// Copyright 2021 OnMetal authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubeconfig
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	matryoshkav1alpha1 "github.com/onmetal/matryoshka/apis/matryoshka/v1alpha1"
+	clientcmdapiv1 "k8s.io/client-go/tools/clientcmd/api/v1"
+)
+
+func sampleConfig() *clientcmdapiv1.Config {
+	return &clientcmdapiv1.Config{
+		Clusters: []clientcmdapiv1.NamedCluster{
+			{Name: "default", Cluster: clientcmdapiv1.Cluster{Server: "https://example.com", CertificateAuthorityData: []byte("ca-data")}},
+		},
+		AuthInfos: []clientcmdapiv1.NamedAuthInfo{
+			{Name: "default", AuthInfo: clientcmdapiv1.AuthInfo{ClientCertificateData: []byte("cert-data"), ClientKeyData: []byte("key-data")}},
+		},
+		CurrentContext: "default",
+	}
+}
+
+func TestNewEncoderUnknownFormat(t *testing.T) {
+	if _, err := NewEncoder("bogus", "kubeconfig"); err == nil {
+		t.Fatal("NewEncoder() with an unknown format should error")
+	}
+}
+
+func TestYAMLEncoder(t *testing.T) {
+	encoder, err := NewEncoder(matryoshkav1alpha1.KubeconfigOutputFormatYAML, "kubeconfig")
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+
+	data, err := encoder.Encode(sampleConfig())
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	out, ok := data["kubeconfig"]
+	if !ok {
+		t.Fatalf("Encode() result missing %q key, got %v", "kubeconfig", data)
+	}
+	if !strings.Contains(string(out), "example.com") {
+		t.Errorf("Encode() output does not contain the cluster server: %s", out)
+	}
+}
+
+func TestJSONEncoder(t *testing.T) {
+	encoder, err := NewEncoder(matryoshkav1alpha1.KubeconfigOutputFormatJSON, "kubeconfig")
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+
+	data, err := encoder.Encode(sampleConfig())
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	var cfg clientcmdapiv1.Config
+	if err := json.Unmarshal(data["kubeconfig"], &cfg); err != nil {
+		t.Fatalf("Encode() produced invalid JSON: %v", err)
+	}
+	if cfg.CurrentContext != "default" {
+		t.Errorf("CurrentContext = %q, want %q", cfg.CurrentContext, "default")
+	}
+}
+
+func TestSplitEncoder(t *testing.T) {
+	encoder, err := NewEncoder(matryoshkav1alpha1.KubeconfigOutputFormatSplit, "kubeconfig")
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+
+	data, err := encoder.Encode(sampleConfig())
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	want := map[string]string{
+		matryoshkav1alpha1.DefaultClusterCertificateAuthorityKey: "ca-data",
+		matryoshkav1alpha1.DefaultAuthInfoClientCertificateKey:   "cert-data",
+		matryoshkav1alpha1.DefaultAuthInfoClientKeyKey:           "key-data",
+	}
+	for key, value := range want {
+		if string(data[key]) != value {
+			t.Errorf("data[%q] = %q, want %q", key, data[key], value)
+		}
+	}
+}
+
+func TestSplitEncoderRejectsMultipleAuthInfosWithSecretData(t *testing.T) {
+	encoder, err := NewEncoder(matryoshkav1alpha1.KubeconfigOutputFormatSplit, "kubeconfig")
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+
+	cfg := sampleConfig()
+	cfg.AuthInfos = append(cfg.AuthInfos, clientcmdapiv1.NamedAuthInfo{
+		Name:     "second",
+		AuthInfo: clientcmdapiv1.AuthInfo{Token: "some-token"},
+	})
+
+	if _, err := encoder.Encode(cfg); err == nil {
+		t.Fatal("Encode() with two auth infos carrying secret data should error instead of silently clobbering one")
+	}
+}
+
+func TestExecCredentialEncoder(t *testing.T) {
+	encoder, err := NewEncoder(matryoshkav1alpha1.KubeconfigOutputFormatExecCredential, "kubeconfig")
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+
+	data, err := encoder.Encode(sampleConfig())
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if !strings.Contains(string(data["kubeconfig"]), "ExecCredential") {
+		t.Errorf("Encode() output does not look like an ExecCredential: %s", data["kubeconfig"])
+	}
+}
+
+func TestExecCredentialEncoderNoAuthInfos(t *testing.T) {
+	encoder, err := NewEncoder(matryoshkav1alpha1.KubeconfigOutputFormatExecCredential, "kubeconfig")
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+
+	if _, err := encoder.Encode(&clientcmdapiv1.Config{}); err == nil {
+		t.Fatal("Encode() with no auth infos should error")
+	}
+}
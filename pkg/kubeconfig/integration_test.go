@@ -0,0 +1,425 @@
+// Copyright 2021 OnMetal authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubeconfig
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	matryoshkav1alpha1 "github.com/onmetal/matryoshka/apis/matryoshka/v1alpha1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatalf("error adding client-go types to scheme: %v", err)
+	}
+	if err := matryoshkav1alpha1.AddToScheme(s); err != nil {
+		t.Fatalf("error adding matryoshka types to scheme: %v", err)
+	}
+	return s
+}
+
+func newTestResolver(t *testing.T, objs ...client.Object) (*Resolver, client.Client) {
+	t.Helper()
+
+	s := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).Build()
+	r, err := NewResolver(ResolverOptions{Client: c, Scheme: s})
+	if err != nil {
+		t.Fatalf("NewResolver() error = %v", err)
+	}
+	return r, c
+}
+
+// TestResolveClientCertificateRequestIssuesApprovesAndRotates exercises
+// resolveClientCertificateRequest end to end against a fake client: submitting a CSR, waiting for
+// it to be signed, persisting the issued certificate, and rotating to a new, distinctly-named CSR
+// once the stored certificate is within its renewal window.
+func TestResolveClientCertificateRequestIssuesApprovesAndRotates(t *testing.T) {
+	r, c := newTestResolver(t)
+	ctx := context.Background()
+
+	csr := &matryoshkav1alpha1.ClientCertificateRequest{
+		Secret:      &matryoshkav1alpha1.SecretSelector{Name: "client-cert"},
+		Subject:     matryoshkav1alpha1.CertificateSubject{CommonName: "test-user"},
+		SignerName:  "kubernetes.io/kube-apiserver-client",
+		Usages:      []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+		RenewBefore: metav1.Duration{Duration: 24 * time.Hour},
+		AutoApprove: true,
+	}
+
+	if _, _, err := r.resolveClientCertificateRequest(ctx, "default", csr); err != ErrCertificateRequestPending {
+		t.Fatalf("resolveClientCertificateRequest() first call error = %v, want %v", err, ErrCertificateRequestPending)
+	}
+
+	csrObj := &certificatesv1.CertificateSigningRequest{}
+	firstCSRName := csrObjectName("default", csr.Secret.Name, "")
+	if err := c.Get(ctx, client.ObjectKey{Name: firstCSRName}, csrObj); err != nil {
+		t.Fatalf("error getting submitted CSR %q: %v", firstCSRName, err)
+	}
+	if !isApproved(csrObj) {
+		t.Fatalf("CSR %q should have been auto-approved", firstCSRName)
+	}
+
+	csrObj.Status.Certificate = encodeCertificate(t, time.Now().Add(time.Hour))
+	if err := c.Status().Update(ctx, csrObj); err != nil {
+		t.Fatalf("error signing CSR: %v", err)
+	}
+
+	certData, keyData, err := r.resolveClientCertificateRequest(ctx, "default", csr)
+	if err != nil {
+		t.Fatalf("resolveClientCertificateRequest() second call error = %v", err)
+	}
+	if len(certData) == 0 || len(keyData) == 0 {
+		t.Fatalf("resolveClientCertificateRequest() returned empty cert/key data")
+	}
+
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: csr.Secret.Name}, secret); err != nil {
+		t.Fatalf("error getting client certificate secret: %v", err)
+	}
+	if string(secret.Data[matryoshkav1alpha1.DefaultAuthInfoClientCertificateKey]) != string(certData) {
+		t.Fatalf("client certificate secret was not persisted with the issued certificate")
+	}
+
+	// The stored certificate expires in 1h with a 24h renewal window, so the next call should
+	// submit a fresh CSR under a new name rather than reusing the signed one.
+	if _, _, err := r.resolveClientCertificateRequest(ctx, "default", csr); err != ErrCertificateRequestPending {
+		t.Fatalf("resolveClientCertificateRequest() rotation call error = %v, want %v", err, ErrCertificateRequestPending)
+	}
+
+	rotatedCSRName := csrObjectName("default", csr.Secret.Name, "1")
+	rotatedCSRObj := &certificatesv1.CertificateSigningRequest{}
+	if err := c.Get(ctx, client.ObjectKey{Name: rotatedCSRName}, rotatedCSRObj); err != nil {
+		t.Fatalf("expected a rotated CSR %q to have been submitted: %v", rotatedCSRName, err)
+	}
+	if err := c.Get(ctx, client.ObjectKey{Name: firstCSRName}, &certificatesv1.CertificateSigningRequest{}); err != nil {
+		t.Fatalf("the original CSR %q should be left untouched: %v", firstCSRName, err)
+	}
+
+	// Sign the rotated CSR and resolve once more: the returned key must be a fresh key pair, not
+	// the one carried over from the certificate being replaced, since key compromise is the usual
+	// reason to rotate.
+	rotatedCSRObj.Status.Certificate = encodeCertificate(t, time.Now().Add(48*time.Hour))
+	if err := c.Status().Update(ctx, rotatedCSRObj); err != nil {
+		t.Fatalf("error signing rotated CSR: %v", err)
+	}
+
+	_, rotatedKeyData, err := r.resolveClientCertificateRequest(ctx, "default", csr)
+	if err != nil {
+		t.Fatalf("resolveClientCertificateRequest() post-rotation call error = %v", err)
+	}
+	if string(rotatedKeyData) == string(keyData) {
+		t.Fatal("resolveClientCertificateRequest() reused the pre-rotation private key instead of generating a fresh one")
+	}
+}
+
+// TestResolveClientCertificateRequestNamespacesCSRName ensures two ClientCertificateRequests in
+// different namespaces that happen to share a Secret name resolve to distinct cluster-scoped CSR
+// objects instead of reading/writing each other's certificate.
+func TestResolveClientCertificateRequestNamespacesCSRName(t *testing.T) {
+	r, c := newTestResolver(t)
+	ctx := context.Background()
+
+	csr := &matryoshkav1alpha1.ClientCertificateRequest{
+		Secret:     &matryoshkav1alpha1.SecretSelector{Name: "shared-name"},
+		Subject:    matryoshkav1alpha1.CertificateSubject{CommonName: "test-user"},
+		SignerName: "kubernetes.io/kube-apiserver-client",
+	}
+
+	if _, _, err := r.resolveClientCertificateRequest(ctx, "tenant-a", csr); err != ErrCertificateRequestPending {
+		t.Fatalf("resolveClientCertificateRequest() tenant-a error = %v, want %v", err, ErrCertificateRequestPending)
+	}
+	if _, _, err := r.resolveClientCertificateRequest(ctx, "tenant-b", csr); err != ErrCertificateRequestPending {
+		t.Fatalf("resolveClientCertificateRequest() tenant-b error = %v, want %v", err, ErrCertificateRequestPending)
+	}
+
+	aName := csrObjectName("tenant-a", csr.Secret.Name, "")
+	bName := csrObjectName("tenant-b", csr.Secret.Name, "")
+	if aName == bName {
+		t.Fatalf("expected distinct CSR names for different namespaces, got %q for both", aName)
+	}
+	if err := c.Get(ctx, client.ObjectKey{Name: aName}, &certificatesv1.CertificateSigningRequest{}); err != nil {
+		t.Fatalf("error getting tenant-a's CSR: %v", err)
+	}
+	if err := c.Get(ctx, client.ObjectKey{Name: bName}, &certificatesv1.CertificateSigningRequest{}); err != nil {
+		t.Fatalf("error getting tenant-b's CSR: %v", err)
+	}
+}
+
+// TestResolveClientCertificateRequestSurfacesDenial ensures a CSR denied by an external
+// approver/policy is reported as the terminal ErrCertificateRequestDenied instead of being
+// re-approved (and Status().Update rejected by the API server) on every subsequent call.
+func TestResolveClientCertificateRequestSurfacesDenial(t *testing.T) {
+	r, c := newTestResolver(t)
+	ctx := context.Background()
+
+	csr := &matryoshkav1alpha1.ClientCertificateRequest{
+		Secret:      &matryoshkav1alpha1.SecretSelector{Name: "client-cert"},
+		Subject:     matryoshkav1alpha1.CertificateSubject{CommonName: "test-user"},
+		SignerName:  "kubernetes.io/kube-apiserver-client",
+		AutoApprove: true,
+	}
+
+	if _, _, err := r.resolveClientCertificateRequest(ctx, "default", csr); err != ErrCertificateRequestPending {
+		t.Fatalf("resolveClientCertificateRequest() first call error = %v, want %v", err, ErrCertificateRequestPending)
+	}
+
+	csrObj := &certificatesv1.CertificateSigningRequest{}
+	csrName := csrObjectName("default", csr.Secret.Name, "")
+	if err := c.Get(ctx, client.ObjectKey{Name: csrName}, csrObj); err != nil {
+		t.Fatalf("error getting submitted CSR %q: %v", csrName, err)
+	}
+	csrObj.Status.Conditions = []certificatesv1.CertificateSigningRequestCondition{{
+		Type:   certificatesv1.CertificateDenied,
+		Status: corev1.ConditionTrue,
+		Reason: "PolicyRejected",
+	}}
+	if err := c.Status().Update(ctx, csrObj); err != nil {
+		t.Fatalf("error denying CSR: %v", err)
+	}
+
+	if _, _, err := r.resolveClientCertificateRequest(ctx, "default", csr); !errors.Is(err, ErrCertificateRequestDenied) {
+		t.Fatalf("resolveClientCertificateRequest() error = %v, want %v", err, ErrCertificateRequestDenied)
+	}
+}
+
+// TestResolveClientCertificateRequestRequiresSecret ensures an omitted Secret is rejected with an
+// error instead of reaching a nil *SecretSelector dereference in resolveClientCertificateRequest.
+func TestResolveClientCertificateRequestRequiresSecret(t *testing.T) {
+	r, _ := newTestResolver(t)
+
+	csr := &matryoshkav1alpha1.ClientCertificateRequest{
+		Subject:    matryoshkav1alpha1.CertificateSubject{CommonName: "test-user"},
+		SignerName: "kubernetes.io/kube-apiserver-client",
+	}
+
+	if _, _, err := r.resolveClientCertificateRequest(context.Background(), "default", csr); err == nil {
+		t.Fatal("resolveClientCertificateRequest() should error when Secret is nil")
+	}
+}
+
+// TestResolveOIDCTokenSourceRequiresRefreshTokenSecret ensures an omitted RefreshTokenSecret is
+// rejected with an error instead of reaching a nil *SecretSelector dereference in
+// resolveOIDCTokenSource.
+func TestResolveOIDCTokenSourceRequiresRefreshTokenSecret(t *testing.T) {
+	r, _ := newTestResolver(t)
+
+	src := &matryoshkav1alpha1.OIDCTokenSource{
+		IssuerURL:    "https://issuer.example.com",
+		ClientID:     "test-client",
+		ClientSecret: matryoshkav1alpha1.OIDCClientSecretSource{Secret: &matryoshkav1alpha1.SecretSelector{Name: "client-secret"}},
+	}
+
+	if _, err := r.resolveOIDCTokenSource(context.Background(), nil, "default", src); err == nil {
+		t.Fatal("resolveOIDCTokenSource() should error when RefreshTokenSecret is nil")
+	}
+}
+
+// TestResolveOIDCTokenSourceCachesAndRefreshes drives resolveOIDCTokenSource against a fake OIDC
+// token endpoint: the first call must exchange the refresh token and persist the minted id_token's
+// own expiry, and a subsequent call while that id_token is still valid must not hit the token
+// endpoint again.
+func TestResolveOIDCTokenSourceCachesAndRefreshes(t *testing.T) {
+	var tokenRequests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		tokenRequests++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"access_token": "access-token",
+			"refresh_token": "rotated-refresh-token",
+			"id_token": "` + encodeIDToken(t, time.Now().Add(time.Hour)) + `",
+			"token_type": "Bearer",
+			"expires_in": 3600
+		}`))
+	}))
+	defer server.Close()
+
+	clientSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "oidc-client-secret"},
+		Data:       map[string][]byte{matryoshkav1alpha1.DefaultOIDCClientSecretKey: []byte("client-secret")},
+	}
+	refreshTokenSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "oidc-refresh-token"},
+		Data:       map[string][]byte{matryoshkav1alpha1.DefaultOIDCRefreshTokenKey: []byte("initial-refresh-token")},
+	}
+
+	r, c := newTestResolver(t, clientSecret, refreshTokenSecret)
+	ctx := context.Background()
+
+	src := &matryoshkav1alpha1.OIDCTokenSource{
+		IssuerURL:          strings.TrimSuffix(server.URL, "/"),
+		ClientID:           "test-client",
+		ClientSecret:       matryoshkav1alpha1.OIDCClientSecretSource{Secret: &matryoshkav1alpha1.SecretSelector{Name: clientSecret.Name}},
+		RefreshTokenSecret: &matryoshkav1alpha1.SecretSelector{Name: refreshTokenSecret.Name},
+	}
+
+	store := newReferenceSet(newTestScheme(t)).local
+	if err := store.Create(ctx, clientSecret.DeepCopy()); err != nil {
+		t.Fatalf("error registering client secret: %v", err)
+	}
+	if err := store.Create(ctx, refreshTokenSecret.DeepCopy()); err != nil {
+		t.Fatalf("error registering refresh token secret: %v", err)
+	}
+	if err := resolveStoreObjects(ctx, c, store); err != nil {
+		t.Fatalf("error resolving store objects: %v", err)
+	}
+
+	authProvider, err := r.resolveOIDCTokenSource(ctx, store, "default", src)
+	if err != nil {
+		t.Fatalf("resolveOIDCTokenSource() first call error = %v", err)
+	}
+	if authProvider.Config["refresh-token"] != "rotated-refresh-token" {
+		t.Errorf("authProvider refresh-token = %q, want %q", authProvider.Config["refresh-token"], "rotated-refresh-token")
+	}
+	if tokenRequests != 1 {
+		t.Fatalf("expected exactly one token request after the first resolve, got %d", tokenRequests)
+	}
+
+	updatedRefreshTokenSecret := &corev1.Secret{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: refreshTokenSecret.Name}, updatedRefreshTokenSecret); err != nil {
+		t.Fatalf("error getting refresh token secret: %v", err)
+	}
+	if len(updatedRefreshTokenSecret.Data[matryoshkav1alpha1.DefaultOIDCIDTokenExpiryKey]) == 0 {
+		t.Fatal("expected the minted id_token's expiry to be persisted")
+	}
+
+	if _, err := r.resolveOIDCTokenSource(ctx, store, "default", src); err != nil {
+		t.Fatalf("resolveOIDCTokenSource() second call error = %v", err)
+	}
+	if tokenRequests != 1 {
+		t.Fatalf("expected the cached id_token to be reused without a second token request, got %d requests", tokenRequests)
+	}
+}
+
+// TestResolveDetectsClusterRefCycleEndToEnd verifies that Resolve, not just withResolving in
+// isolation, rejects a ClusterSecretSelector/ClusterReference chain that loops back on itself
+// instead of recursing through clusterClientCache.build until the stack overflows.
+func TestResolveDetectsClusterRefCycleEndToEnd(t *testing.T) {
+	secretSourceViaCluster := func(clusterRef string) *matryoshkav1alpha1.SecretValueSource {
+		return &matryoshkav1alpha1.SecretValueSource{
+			Secret: &matryoshkav1alpha1.ClusterSecretSelector{
+				SecretSelector: matryoshkav1alpha1.SecretSelector{Name: "shared-secret"},
+				ClusterRef:     &matryoshkav1alpha1.ClusterReference{Name: clusterRef},
+			},
+		}
+	}
+
+	kubeconfigA := &matryoshkav1alpha1.Kubeconfig{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"},
+		Spec: matryoshkav1alpha1.KubeconfigSpec{
+			Clusters: []matryoshkav1alpha1.NamedCluster{
+				{Name: "c", Cluster: matryoshkav1alpha1.Cluster{
+					Server:               "https://a.example.com",
+					CertificateAuthority: secretSourceViaCluster("b"),
+				}},
+			},
+		},
+	}
+	kubeconfigB := &matryoshkav1alpha1.Kubeconfig{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "b"},
+		Spec: matryoshkav1alpha1.KubeconfigSpec{
+			Clusters: []matryoshkav1alpha1.NamedCluster{
+				{Name: "c", Cluster: matryoshkav1alpha1.Cluster{
+					Server:               "https://b.example.com",
+					CertificateAuthority: secretSourceViaCluster("a"),
+				}},
+			},
+		},
+	}
+
+	r, _ := newTestResolver(t, kubeconfigA, kubeconfigB)
+
+	_, err := r.Resolve(context.Background(), kubeconfigA)
+	if err == nil {
+		t.Fatal("Resolve() should error on a ClusterRef cycle")
+	}
+	if !strings.Contains(err.Error(), "cluster reference cycle detected") {
+		t.Fatalf("Resolve() error = %v, want it to mention a cluster reference cycle", err)
+	}
+}
+
+// TestReconcileOutputCreatesUpdatesAndIsIdempotent drives ReconcileOutput against a fake client:
+// the first call must create the output Secret, a second call with a changed cfg must update it in
+// place, and a third call with the same cfg as the second must be a no-op write that still leaves
+// the Secret holding the second call's data.
+func TestReconcileOutputCreatesUpdatesAndIsIdempotent(t *testing.T) {
+	r, c := newTestResolver(t)
+	ctx := context.Background()
+
+	kubeconfig := &matryoshkav1alpha1.Kubeconfig{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"},
+		Spec: matryoshkav1alpha1.KubeconfigSpec{
+			Output: &matryoshkav1alpha1.KubeconfigOutput{
+				SecretRef: corev1.LocalObjectReference{Name: "kubeconfig-output"},
+				Formats:   []matryoshkav1alpha1.KubeconfigOutputFormat{matryoshkav1alpha1.KubeconfigOutputFormatYAML},
+			},
+		},
+	}
+
+	if err := r.ReconcileOutput(ctx, kubeconfig, sampleConfig()); err != nil {
+		t.Fatalf("ReconcileOutput() create error = %v", err)
+	}
+
+	secret := &corev1.Secret{}
+	secretKey := client.ObjectKey{Namespace: "default", Name: "kubeconfig-output"}
+	if err := c.Get(ctx, secretKey, secret); err != nil {
+		t.Fatalf("error getting output secret after create: %v", err)
+	}
+	firstData := secret.Data[matryoshkav1alpha1.DefaultKubeconfigOutputKey]
+	if !strings.Contains(string(firstData), "example.com") {
+		t.Fatalf("output secret does not contain the expected cluster server: %s", firstData)
+	}
+
+	updatedConfig := sampleConfig()
+	updatedConfig.Clusters[0].Cluster.Server = "https://updated.example.com"
+	if err := r.ReconcileOutput(ctx, kubeconfig, updatedConfig); err != nil {
+		t.Fatalf("ReconcileOutput() update error = %v", err)
+	}
+
+	if err := c.Get(ctx, secretKey, secret); err != nil {
+		t.Fatalf("error getting output secret after update: %v", err)
+	}
+	secondData := secret.Data[matryoshkav1alpha1.DefaultKubeconfigOutputKey]
+	if !strings.Contains(string(secondData), "updated.example.com") {
+		t.Fatalf("output secret was not updated with the new cluster server: %s", secondData)
+	}
+
+	if err := r.ReconcileOutput(ctx, kubeconfig, updatedConfig); err != nil {
+		t.Fatalf("ReconcileOutput() idempotent call error = %v", err)
+	}
+
+	if err := c.Get(ctx, secretKey, secret); err != nil {
+		t.Fatalf("error getting output secret after idempotent call: %v", err)
+	}
+	if string(secret.Data[matryoshkav1alpha1.DefaultKubeconfigOutputKey]) != string(secondData) {
+		t.Fatalf("output secret changed on a reconcile with an unchanged cfg")
+	}
+}
@@ -16,45 +16,82 @@ package kubeconfig
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	matryoshkav1alpha1 "github.com/onmetal/matryoshka/apis/matryoshka/v1alpha1"
 	"github.com/onmetal/matryoshka/pkg/memorystore"
 	"github.com/onmetal/matryoshka/pkg/utils"
 	"github.com/onmetal/matryoshka/pkg/utils/multigetter"
+	"golang.org/x/oauth2"
+	"golang.org/x/sync/errgroup"
+	certificatesv1 "k8s.io/api/certificates/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientcmdapiv1 "k8s.io/client-go/tools/clientcmd/api/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// ErrCertificateRequestPending is returned by resolveClientCertificateRequest when a submitted
+// CertificateSigningRequest has not yet been signed. Callers of Resolve should treat this as a
+// request to requeue rather than a terminal error.
+var ErrCertificateRequestPending = errors.New("kubeconfig: certificate signing request is pending")
+
+// ErrCertificateRequestDenied is returned by resolveClientCertificateRequest when a submitted
+// CertificateSigningRequest was denied or failed rather than signed. Unlike
+// ErrCertificateRequestPending, requeuing Resolve will not make this succeed - the CSR needs to be
+// resubmitted (e.g. by bumping ClientCertificateRequest in a way that changes csrObjectName, or
+// deleting the denied CSR object) before another attempt can proceed.
+var ErrCertificateRequestDenied = errors.New("kubeconfig: certificate signing request was denied")
+
 type Resolver struct {
 	scheme *runtime.Scheme
 	client client.Client
+
+	clusterClientTTL time.Duration
+	clusterClients   *clusterClientCache
 }
 
-func (r *Resolver) createKubeconfigReferences(ctx context.Context, s *memorystore.Store, kubeconfig *matryoshkav1alpha1.Kubeconfig) error {
+func (r *Resolver) createKubeconfigReferences(ctx context.Context, rs *ReferenceSet, kubeconfig *matryoshkav1alpha1.Kubeconfig) error {
 	for _, authInfo := range kubeconfig.Spec.AuthInfos {
-		if err := r.createAuthInfoReferences(ctx, s, kubeconfig.Namespace, &authInfo.AuthInfo); err != nil {
+		if err := r.createAuthInfoReferences(ctx, rs, kubeconfig.Namespace, &authInfo.AuthInfo); err != nil {
 			return err
 		}
 	}
 	for _, cluster := range kubeconfig.Spec.Clusters {
-		if err := r.createClusterReferences(ctx, s, kubeconfig.Namespace, &cluster.Cluster); err != nil {
+		if err := r.createClusterReferences(ctx, rs, kubeconfig.Namespace, &cluster.Cluster); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (r *Resolver) createClusterReferences(ctx context.Context, s *memorystore.Store, namespace string, cluster *matryoshkav1alpha1.Cluster) error {
+// createClusterSecretReference registers name/key in the store for selector's ClusterRef (the
+// local store if unset), under selector's effective namespace.
+func createClusterSecretReference(ctx context.Context, rs *ReferenceSet, namespace string, selector *matryoshkav1alpha1.ClusterSecretSelector) error {
+	return utils.IgnoreAlreadyExists(rs.storeFor(selector.ClusterRef).Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespaceFor(selector, namespace),
+			Name:      selector.Name,
+		},
+	}))
+}
+
+func (r *Resolver) createClusterReferences(ctx context.Context, rs *ReferenceSet, namespace string, cluster *matryoshkav1alpha1.Cluster) error {
 	if certificateAuthority := cluster.CertificateAuthority; certificateAuthority != nil {
-		if err := utils.IgnoreAlreadyExists(s.Create(ctx, &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Namespace: namespace,
-				Name:      certificateAuthority.Secret.Name,
-			},
-		})); err != nil {
+		if err := createClusterSecretReference(ctx, rs, namespace, certificateAuthority.Secret); err != nil {
 			return err
 		}
 	}
@@ -62,45 +99,48 @@ func (r *Resolver) createClusterReferences(ctx context.Context, s *memorystore.S
 	return nil
 }
 
-func (r *Resolver) createAuthInfoReferences(ctx context.Context, s *memorystore.Store, namespace string, authInfo *matryoshkav1alpha1.AuthInfo) error {
+func (r *Resolver) createAuthInfoReferences(ctx context.Context, rs *ReferenceSet, namespace string, authInfo *matryoshkav1alpha1.AuthInfo) error {
 	if clientCertificate := authInfo.ClientCertificate; clientCertificate != nil {
-		if err := utils.IgnoreAlreadyExists(s.Create(ctx, &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Namespace: namespace,
-				Name:      clientCertificate.Secret.Name,
-			},
-		})); err != nil {
+		if err := createClusterSecretReference(ctx, rs, namespace, clientCertificate.Secret); err != nil {
 			return err
 		}
 	}
 
 	if clientKey := authInfo.ClientKey; clientKey != nil {
-		if err := utils.IgnoreAlreadyExists(s.Create(ctx, &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Namespace: namespace,
-				Name:      clientKey.Secret.Name,
-			},
-		})); err != nil {
+		if err := createClusterSecretReference(ctx, rs, namespace, clientKey.Secret); err != nil {
 			return err
 		}
 	}
 
 	if token := authInfo.Token; token != nil {
-		if err := utils.IgnoreAlreadyExists(s.Create(ctx, &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Namespace: namespace,
-				Name:      token.Secret.Name,
-			},
-		})); err != nil {
+		if err := createClusterSecretReference(ctx, rs, namespace, token.Secret); err != nil {
 			return err
 		}
 	}
 
 	if password := authInfo.Password; password != nil {
-		if err := utils.IgnoreAlreadyExists(s.Create(ctx, &corev1.Secret{
+		if err := createClusterSecretReference(ctx, rs, namespace, password.Secret); err != nil {
+			return err
+		}
+	}
+
+	if exec := authInfo.Exec; exec != nil {
+		if err := r.createExecEnvReferences(ctx, rs.local, namespace, exec.Env); err != nil {
+			return err
+		}
+	}
+
+	if oidc := authInfo.OIDCTokenSource; oidc != nil {
+		if oidc.ClientSecret.Secret == nil {
+			return fmt.Errorf("oidcTokenSource.clientSecret.secret is required")
+		}
+		if oidc.RefreshTokenSecret == nil {
+			return fmt.Errorf("oidcTokenSource.refreshTokenSecret is required")
+		}
+		if err := utils.IgnoreAlreadyExists(rs.local.Create(ctx, &corev1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
 				Namespace: namespace,
-				Name:      password.Secret.Name,
+				Name:      oidc.ClientSecret.Secret.Name,
 			},
 		})); err != nil {
 			return err
@@ -110,40 +150,129 @@ func (r *Resolver) createAuthInfoReferences(ctx context.Context, s *memorystore.
 	return nil
 }
 
-func (r *Resolver) ObjectReferences(ctx context.Context, kubeconfig *matryoshkav1alpha1.Kubeconfig) (*memorystore.Store, error) {
-	s := memorystore.New(r.scheme)
+func (r *Resolver) createExecEnvReferences(ctx context.Context, s *memorystore.Store, namespace string, env []matryoshkav1alpha1.ExecEnvVar) error {
+	for _, e := range env {
+		valueFrom := e.ValueFrom
+		if valueFrom == nil {
+			continue
+		}
+
+		if secretRef := valueFrom.SecretKeyRef; secretRef != nil {
+			if err := utils.IgnoreAlreadyExists(s.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: namespace,
+					Name:      secretRef.Name,
+				},
+			})); err != nil {
+				return err
+			}
+		}
+
+		if configMapRef := valueFrom.ConfigMapKeyRef; configMapRef != nil {
+			if err := utils.IgnoreAlreadyExists(s.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: namespace,
+					Name:      configMapRef.Name,
+				},
+			})); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *Resolver) ObjectReferences(ctx context.Context, kubeconfig *matryoshkav1alpha1.Kubeconfig) (*ReferenceSet, error) {
+	rs := newReferenceSet(r.scheme)
 
-	if err := r.createKubeconfigReferences(ctx, s, kubeconfig); err != nil {
+	if err := r.createKubeconfigReferences(ctx, rs, kubeconfig); err != nil {
 		return nil, err
 	}
 
-	return s, nil
+	return rs, nil
+}
+
+// resolveKubeconfigObjects resolves the local store against the controller's own cluster, and
+// each remote store against a lazily-built client for its ClusterRef, so that every store's
+// objects are batched through a single MultiGet call against the API server that owns them. The
+// local store and every remote store are resolved concurrently, so a Kubeconfig referencing many
+// clusters fans out to all of their API servers in parallel instead of dialing them one at a time.
+func (r *Resolver) resolveKubeconfigObjects(ctx context.Context, namespace string, rs *ReferenceSet) error {
+	eg, ctx := errgroup.WithContext(ctx)
+
+	eg.Go(func() error {
+		if err := resolveStoreObjects(ctx, r.client, rs.local); err != nil {
+			return fmt.Errorf("error resolving local objects: %w", err)
+		}
+		return nil
+	})
+
+	for name, s := range rs.remote {
+		name, s := name, s
+		eg.Go(func() error {
+			remoteClient, err := r.clusterClients.get(ctx, namespace, &matryoshkav1alpha1.ClusterReference{Name: name})
+			if err != nil {
+				return fmt.Errorf("error building client for cluster reference %q: %w", name, err)
+			}
+
+			if err := resolveStoreObjects(ctx, remoteClient, s); err != nil {
+				return fmt.Errorf("error resolving objects for cluster reference %q: %w", name, err)
+			}
+			return nil
+		})
+	}
+
+	return eg.Wait()
 }
 
-func (r *Resolver) resolveKubeconfigObjects(ctx context.Context, s *memorystore.Store) error {
-	mg, err := multigetter.New(multigetter.Options{Client: r.client})
+func resolveStoreObjects(ctx context.Context, c client.Client, s *memorystore.Store) error {
+	mg, err := multigetter.New(multigetter.Options{Client: c})
 	if err != nil {
 		return err
 	}
 
-	if err := mg.MultiGet(ctx, multigetter.RequestsFromObjects(s.Objects())...); err != nil {
-		return err
+	return mg.MultiGet(ctx, multigetter.RequestsFromObjects(s.Objects())...)
+}
+
+// resolvingKey is the context.Value key under which Resolve tracks the set of Kubeconfigs
+// currently being resolved, to detect ClusterRef cycles.
+type resolvingKey struct{}
+
+// withResolving returns a copy of ctx recording that the Kubeconfig identified by key is now being
+// resolved, or an error if key is already being resolved somewhere up the call stack - i.e. a
+// ClusterSecretSelector/ClusterReference chain loops back on itself.
+func withResolving(ctx context.Context, key string) (context.Context, error) {
+	resolving, _ := ctx.Value(resolvingKey{}).(map[string]struct{})
+	if _, ok := resolving[key]; ok {
+		return nil, fmt.Errorf("kubeconfig: cluster reference cycle detected: %q is already being resolved", key)
 	}
 
-	return nil
+	next := make(map[string]struct{}, len(resolving)+1)
+	for k := range resolving {
+		next[k] = struct{}{}
+	}
+	next[key] = struct{}{}
+
+	return context.WithValue(ctx, resolvingKey{}, next), nil
 }
 
 func (r *Resolver) Resolve(ctx context.Context, kubeconfig *matryoshkav1alpha1.Kubeconfig) (*clientcmdapiv1.Config, error) {
-	s, err := r.ObjectReferences(ctx, kubeconfig)
+	ctx, err := withResolving(ctx, kubeconfig.Namespace+"/"+kubeconfig.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := r.ObjectReferences(ctx, kubeconfig)
 	if err != nil {
 		return nil, fmt.Errorf("error determining objects referenced by kubeconfig: %w", err)
 	}
 
-	if err := r.resolveKubeconfigObjects(ctx, s); err != nil {
+	if err := r.resolveKubeconfigObjects(ctx, kubeconfig.Namespace, rs); err != nil {
 		return nil, fmt.Errorf("error resolving objects referenced by kubeconfig: %w", err)
 	}
 
-	cfg, err := r.resolveKubeconfig(ctx, s, kubeconfig)
+	cfg, err := r.resolveKubeconfig(ctx, rs, kubeconfig)
 	if err != nil {
 		return nil, fmt.Errorf("error resolving kubeconfig to config: %w", err)
 	}
@@ -151,10 +280,49 @@ func (r *Resolver) Resolve(ctx context.Context, kubeconfig *matryoshkav1alpha1.K
 	return cfg, nil
 }
 
-func (r *Resolver) resolveKubeconfig(ctx context.Context, s *memorystore.Store, kubeconfig *matryoshkav1alpha1.Kubeconfig) (*clientcmdapiv1.Config, error) {
+// ReconcileOutput renders cfg through every format configured in kubeconfig.Spec.Output and
+// creates or updates the target Secret with the resulting keys.
+func (r *Resolver) ReconcileOutput(ctx context.Context, kubeconfig *matryoshkav1alpha1.Kubeconfig, cfg *clientcmdapiv1.Config) error {
+	output := kubeconfig.Spec.Output
+	if output == nil {
+		return nil
+	}
+
+	data, err := EncodeOutput(cfg, output)
+	if err != nil {
+		return fmt.Errorf("error encoding kubeconfig output: %w", err)
+	}
+
+	secret := &corev1.Secret{}
+	secretKey := client.ObjectKey{Namespace: kubeconfig.Namespace, Name: output.SecretRef.Name}
+	if err := r.client.Get(ctx, secretKey, secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("error getting kubeconfig output secret: %w", err)
+		}
+
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: kubeconfig.Namespace, Name: output.SecretRef.Name},
+			Data:       data,
+		}
+		if err := r.client.Create(ctx, secret); err != nil {
+			return fmt.Errorf("error creating kubeconfig output secret: %w", err)
+		}
+
+		return nil
+	}
+
+	secret.Data = data
+	if err := r.client.Update(ctx, secret); err != nil {
+		return fmt.Errorf("error updating kubeconfig output secret: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Resolver) resolveKubeconfig(ctx context.Context, rs *ReferenceSet, kubeconfig *matryoshkav1alpha1.Kubeconfig) (*clientcmdapiv1.Config, error) {
 	authInfos := make([]clientcmdapiv1.NamedAuthInfo, 0, len(kubeconfig.Spec.AuthInfos))
 	for _, authInfo := range kubeconfig.Spec.AuthInfos {
-		resolved, err := r.resolveAuthInfo(ctx, s, kubeconfig.Namespace, &authInfo.AuthInfo)
+		resolved, err := r.resolveAuthInfo(ctx, rs, kubeconfig.Namespace, &authInfo.AuthInfo)
 		if err != nil {
 			return nil, err
 		}
@@ -164,7 +332,7 @@ func (r *Resolver) resolveKubeconfig(ctx context.Context, s *memorystore.Store,
 
 	clusters := make([]clientcmdapiv1.NamedCluster, 0, len(kubeconfig.Spec.Clusters))
 	for _, cluster := range kubeconfig.Spec.Clusters {
-		resolved, err := r.resolveCluster(ctx, s, kubeconfig.Namespace, &cluster.Cluster)
+		resolved, err := r.resolveCluster(ctx, rs, kubeconfig.Namespace, &cluster.Cluster)
 		if err != nil {
 			return nil, err
 		}
@@ -194,14 +362,14 @@ func (r *Resolver) resolveKubeconfig(ctx context.Context, s *memorystore.Store,
 
 func (r *Resolver) resolveAuthInfo(
 	ctx context.Context,
-	s *memorystore.Store,
+	rs *ReferenceSet,
 	namespace string,
 	authInfo *matryoshkav1alpha1.AuthInfo,
 ) (*clientcmdapiv1.AuthInfo, error) {
 	var clientCertificateData []byte
 	if clientCertificate := authInfo.ClientCertificate; clientCertificate != nil {
 		var err error
-		clientCertificateData, err = utils.GetSecretSelector(ctx, s, namespace, *clientCertificate.Secret, matryoshkav1alpha1.DefaultAuthInfoClientCertificateKey)
+		clientCertificateData, err = getClusterSecretSelector(ctx, rs, namespace, clientCertificate.Secret, matryoshkav1alpha1.DefaultAuthInfoClientCertificateKey)
 		if err != nil {
 			return nil, err
 		}
@@ -210,7 +378,15 @@ func (r *Resolver) resolveAuthInfo(
 	var clientKeyData []byte
 	if clientKey := authInfo.ClientKey; clientKey != nil {
 		var err error
-		clientKeyData, err = utils.GetSecretSelector(ctx, s, namespace, *clientKey.Secret, matryoshkav1alpha1.DefaultAuthInfoClientKeyKey)
+		clientKeyData, err = getClusterSecretSelector(ctx, rs, namespace, clientKey.Secret, matryoshkav1alpha1.DefaultAuthInfoClientKeyKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if csr := authInfo.ClientCertificateRequest; csr != nil {
+		var err error
+		clientCertificateData, clientKeyData, err = r.resolveClientCertificateRequest(ctx, namespace, csr)
 		if err != nil {
 			return nil, err
 		}
@@ -222,7 +398,7 @@ func (r *Resolver) resolveAuthInfo(
 			tokenData []byte
 			err       error
 		)
-		tokenData, err = utils.GetSecretSelector(ctx, s, namespace, *tok.Secret, matryoshkav1alpha1.DefaultAuthInfoTokenKey)
+		tokenData, err = getClusterSecretSelector(ctx, rs, namespace, tok.Secret, matryoshkav1alpha1.DefaultAuthInfoTokenKey)
 		if err != nil {
 			return nil, err
 		}
@@ -236,7 +412,7 @@ func (r *Resolver) resolveAuthInfo(
 			passwordData []byte
 			err          error
 		)
-		passwordData, err = utils.GetSecretSelector(ctx, s, namespace, *pwd.Secret, matryoshkav1alpha1.DefaultAuthInfoPasswordKey)
+		passwordData, err = getClusterSecretSelector(ctx, rs, namespace, pwd.Secret, matryoshkav1alpha1.DefaultAuthInfoPasswordKey)
 		if err != nil {
 			return nil, err
 		}
@@ -244,6 +420,31 @@ func (r *Resolver) resolveAuthInfo(
 		password = string(passwordData)
 	}
 
+	var exec *clientcmdapiv1.ExecConfig
+	if authInfo.Exec != nil {
+		var err error
+		exec, err = r.resolveExec(ctx, rs.local, namespace, authInfo.Exec)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var authProvider *clientcmdapiv1.AuthProviderConfig
+	if ap := authInfo.AuthProvider; ap != nil {
+		authProvider = &clientcmdapiv1.AuthProviderConfig{
+			Name:   ap.Name,
+			Config: ap.Config,
+		}
+	}
+
+	if oidc := authInfo.OIDCTokenSource; oidc != nil {
+		var err error
+		authProvider, err = r.resolveOIDCTokenSource(ctx, rs.local, namespace, oidc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &clientcmdapiv1.AuthInfo{
 		ClientCertificateData: clientCertificateData,
 		ClientKeyData:         clientKeyData,
@@ -252,14 +453,447 @@ func (r *Resolver) resolveAuthInfo(
 		ImpersonateGroups:     authInfo.ImpersonateGroups,
 		Username:              authInfo.Username,
 		Password:              password,
+		Exec:                  exec,
+		AuthProvider:          authProvider,
+	}, nil
+}
+
+func (r *Resolver) resolveExec(
+	ctx context.Context,
+	s *memorystore.Store,
+	namespace string,
+	exec *matryoshkav1alpha1.ExecConfig,
+) (*clientcmdapiv1.ExecConfig, error) {
+	env := make([]clientcmdapiv1.ExecEnvVar, 0, len(exec.Env))
+	for _, e := range exec.Env {
+		value := e.Value
+		if valueFrom := e.ValueFrom; valueFrom != nil {
+			var (
+				data []byte
+				err  error
+			)
+			switch {
+			case valueFrom.SecretKeyRef != nil:
+				data, err = utils.GetSecretSelector(ctx, s, namespace, *valueFrom.SecretKeyRef, valueFrom.SecretKeyRef.Key)
+			case valueFrom.ConfigMapKeyRef != nil:
+				data, err = utils.GetConfigMapSelector(ctx, s, namespace, *valueFrom.ConfigMapKeyRef, valueFrom.ConfigMapKeyRef.Key)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			value = string(data)
+		}
+
+		env = append(env, clientcmdapiv1.ExecEnvVar{Name: e.Name, Value: value})
+	}
+
+	return &clientcmdapiv1.ExecConfig{
+		Command:            exec.Command,
+		Args:               exec.Args,
+		Env:                env,
+		APIVersion:         exec.APIVersion,
+		InstallHint:        exec.InstallHint,
+		ProvideClusterInfo: exec.ProvideClusterInfo,
+		InteractiveMode:    clientcmdapiv1.ExecInteractiveMode(exec.InteractiveMode),
 	}, nil
 }
 
-func (r *Resolver) resolveCluster(ctx context.Context, s *memorystore.Store, namespace string, cluster *matryoshkav1alpha1.Cluster) (*clientcmdapiv1.Cluster, error) {
+// oidcIDTokenExpiryLeeway is subtracted from a cached id_token's stored expiry so
+// resolveOIDCTokenSource refreshes slightly before the token actually expires.
+const oidcIDTokenExpiryLeeway = time.Minute
+
+// resolveOIDCTokenSource exchanges the refresh token stored in the referenced Secret for a fresh
+// id_token via the configured OIDC issuer, persists the refreshed tokens (and the minted
+// id_token's expiry) back into the Secret, and returns an AuthProviderConfig in the shape
+// kubectl's built-in "oidc" auth-provider expects. If the Secret already holds an id_token that
+// has not reached its cached expiry, the refresh-token exchange is skipped entirely: many OIDC
+// providers issue single-use refresh tokens, and Resolve may be called once per reconcile, so
+// exchanging on every call would burn through them and race concurrent reconciles against the
+// same refresh token.
+func (r *Resolver) resolveOIDCTokenSource(
+	ctx context.Context,
+	s *memorystore.Store,
+	namespace string,
+	src *matryoshkav1alpha1.OIDCTokenSource,
+) (*clientcmdapiv1.AuthProviderConfig, error) {
+	if src.ClientSecret.Secret == nil {
+		return nil, fmt.Errorf("oidcTokenSource.clientSecret.secret is required")
+	}
+	if src.RefreshTokenSecret == nil {
+		return nil, fmt.Errorf("oidcTokenSource.refreshTokenSecret is required")
+	}
+
+	clientSecretData, err := utils.GetSecretSelector(ctx, s, namespace, *src.ClientSecret.Secret, matryoshkav1alpha1.DefaultOIDCClientSecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshTokenSecret := &corev1.Secret{}
+	if err := r.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: src.RefreshTokenSecret.Name}, refreshTokenSecret); err != nil {
+		return nil, fmt.Errorf("error getting refresh token secret: %w", err)
+	}
+
+	refreshTokenKey := src.RefreshTokenSecret.Key
+	if refreshTokenKey == "" {
+		refreshTokenKey = matryoshkav1alpha1.DefaultOIDCRefreshTokenKey
+	}
+
+	if cached := cachedOIDCAuthProviderConfig(refreshTokenSecret, refreshTokenKey, src, clientSecretData); cached != nil {
+		return cached, nil
+	}
+
+	oauth2Cfg := oauth2.Config{
+		ClientID:     src.ClientID,
+		ClientSecret: string(clientSecretData),
+		Endpoint: oauth2.Endpoint{
+			TokenURL: strings.TrimSuffix(src.IssuerURL, "/") + "/token",
+		},
+		Scopes: src.Scopes,
+	}
+
+	tokenSource := oauth2Cfg.TokenSource(ctx, &oauth2.Token{
+		RefreshToken: string(refreshTokenSecret.Data[refreshTokenKey]),
+	})
+
+	token, err := tokenSource.Token()
+	if err != nil {
+		return nil, fmt.Errorf("error refreshing oidc token: %w", err)
+	}
+
+	idToken, ok := token.Extra("id_token").(string)
+	if !ok || idToken == "" {
+		return nil, fmt.Errorf("oidc token response did not contain an id_token")
+	}
+
+	refreshTokenSecret.Data[refreshTokenKey] = []byte(token.RefreshToken)
+	refreshTokenSecret.Data[matryoshkav1alpha1.DefaultOIDCIDTokenKey] = []byte(idToken)
+	if idTokenExp, err := idTokenExpiry(idToken); err != nil {
+		delete(refreshTokenSecret.Data, matryoshkav1alpha1.DefaultOIDCIDTokenExpiryKey)
+	} else {
+		refreshTokenSecret.Data[matryoshkav1alpha1.DefaultOIDCIDTokenExpiryKey] = []byte(idTokenExp.Format(time.RFC3339))
+	}
+	if err := r.client.Update(ctx, refreshTokenSecret); err != nil {
+		return nil, fmt.Errorf("error persisting refreshed oidc tokens: %w", err)
+	}
+
+	return buildOIDCAuthProviderConfig(idToken, token.RefreshToken, src, clientSecretData), nil
+}
+
+// idTokenExpiry returns the "exp" claim of idToken, an OIDC id_token JWT. It does not verify the
+// token's signature - the token was just minted by the issuer over a direct, authenticated
+// connection, so this is only used to decide when to re-mint it, not to establish trust.
+func idTokenExpiry(idToken string) (time.Time, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("id_token is not a well-formed JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error decoding id_token payload: %w", err)
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("error parsing id_token claims: %w", err)
+	}
+	if claims.Exp == 0 {
+		return time.Time{}, fmt.Errorf("id_token has no exp claim")
+	}
+
+	return time.Unix(claims.Exp, 0), nil
+}
+
+// cachedOIDCAuthProviderConfig returns an AuthProviderConfig built from secret's cached id_token if
+// it is still valid, or nil if there is no cached id_token, no cached expiry, or the cached expiry
+// is within oidcIDTokenExpiryLeeway of now.
+func cachedOIDCAuthProviderConfig(
+	secret *corev1.Secret,
+	refreshTokenKey string,
+	src *matryoshkav1alpha1.OIDCTokenSource,
+	clientSecretData []byte,
+) *clientcmdapiv1.AuthProviderConfig {
+	idToken := string(secret.Data[matryoshkav1alpha1.DefaultOIDCIDTokenKey])
+	if idToken == "" {
+		return nil
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, string(secret.Data[matryoshkav1alpha1.DefaultOIDCIDTokenExpiryKey]))
+	if err != nil || time.Until(expiresAt) < oidcIDTokenExpiryLeeway {
+		return nil
+	}
+
+	return buildOIDCAuthProviderConfig(idToken, string(secret.Data[refreshTokenKey]), src, clientSecretData)
+}
+
+func buildOIDCAuthProviderConfig(idToken, refreshToken string, src *matryoshkav1alpha1.OIDCTokenSource, clientSecretData []byte) *clientcmdapiv1.AuthProviderConfig {
+	return &clientcmdapiv1.AuthProviderConfig{
+		Name: "oidc",
+		Config: map[string]string{
+			"id-token":       idToken,
+			"refresh-token":  refreshToken,
+			"idp-issuer-url": src.IssuerURL,
+			"client-id":      src.ClientID,
+			"client-secret":  string(clientSecretData),
+		},
+	}
+}
+
+// csrGenerationAnnotation records which generation of CertificateSigningRequest a client
+// certificate Secret's contents were issued from. resolveClientCertificateRequest bumps it (and
+// the CSR object name it derives) whenever an already-signed certificate is due for renewal, since
+// re-submitting a CSR under the same name as a already-signed one would just read back the same
+// stale status.certificate forever.
+const csrGenerationAnnotation = "kubeconfig.matryoshka.onmetal.de/csr-generation"
+
+// resolveClientCertificateRequest ensures a client certificate/key pair backed by a Kubernetes
+// CertificateSigningRequest exists in the target Secret, generating a private key and submitting a
+// new CSR on first use or when the current certificate is within its renewal window, and returns
+// ErrCertificateRequestPending while waiting for the CSR to be signed. Rotation always mints a
+// fresh key pair alongside the new CSR rather than reusing the one being replaced.
+func (r *Resolver) resolveClientCertificateRequest(
+	ctx context.Context,
+	namespace string,
+	csr *matryoshkav1alpha1.ClientCertificateRequest,
+) ([]byte, []byte, error) {
+	if csr.Secret == nil {
+		return nil, nil, fmt.Errorf("clientCertificateRequest.secret is required")
+	}
+
+	secret := &corev1.Secret{}
+	secretKey := client.ObjectKey{Namespace: namespace, Name: csr.Secret.Name}
+	if err := r.client.Get(ctx, secretKey, secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, nil, fmt.Errorf("error getting client certificate request secret: %w", err)
+		}
+		secret = &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: csr.Secret.Name}}
+	}
+
+	certData := secret.Data[matryoshkav1alpha1.DefaultAuthInfoClientCertificateKey]
+	keyData := secret.Data[matryoshkav1alpha1.DefaultAuthInfoClientKeyKey]
+	haveCert := len(certData) > 0 && len(keyData) > 0
+
+	if haveCert && !needsRenewal(certData, csr.RenewBefore.Duration) {
+		return certData, keyData, nil
+	}
+
+	var err error
+	if haveCert {
+		// Rotating an already-issued certificate: always mint a fresh key pair rather than
+		// reusing the one being replaced, since key compromise is the usual reason to rotate.
+		keyData, err = generatePrivateKey()
+	} else {
+		keyData, err = ensurePrivateKey(keyData)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error ensuring client certificate request private key: %w", err)
+	}
+
+	generation := secret.Annotations[csrGenerationAnnotation]
+	if haveCert {
+		generation = nextCSRGeneration(generation)
+	}
+	csrObjName := csrObjectName(namespace, csr.Secret.Name, generation)
+
+	csrObj := &certificatesv1.CertificateSigningRequest{}
+	if err := r.client.Get(ctx, client.ObjectKey{Name: csrObjName}, csrObj); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, nil, fmt.Errorf("error getting certificate signing request: %w", err)
+		}
+
+		requestBytes, err := buildCertificateRequest(csr, keyData)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error building certificate signing request: %w", err)
+		}
+
+		csrObj = &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: csrObjName},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Request:    requestBytes,
+				SignerName: csr.SignerName,
+				Usages:     csr.Usages,
+			},
+		}
+		if csr.ValidityDuration != nil {
+			expirationSeconds := int32(csr.ValidityDuration.Duration.Seconds())
+			csrObj.Spec.ExpirationSeconds = &expirationSeconds
+		}
+		if err := r.client.Create(ctx, csrObj); err != nil {
+			return nil, nil, fmt.Errorf("error creating certificate signing request: %w", err)
+		}
+	}
+
+	if isDenied(csrObj) {
+		return nil, nil, fmt.Errorf("%w: %q", ErrCertificateRequestDenied, csrObj.Name)
+	}
+
+	if csr.AutoApprove && !isApproved(csrObj) {
+		csrObj.Status.Conditions = append(csrObj.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
+			Type:    certificatesv1.CertificateApproved,
+			Status:  corev1.ConditionTrue,
+			Reason:  "MatryoshkaAutoApprove",
+			Message: "Automatically approved by matryoshka kubeconfig resolver",
+		})
+		if err := r.client.Status().Update(ctx, csrObj); err != nil {
+			return nil, nil, fmt.Errorf("error approving certificate signing request: %w", err)
+		}
+	}
+
+	if len(csrObj.Status.Certificate) == 0 {
+		return nil, nil, ErrCertificateRequestPending
+	}
+
+	secret.Data = map[string][]byte{
+		matryoshkav1alpha1.DefaultAuthInfoClientCertificateKey: csrObj.Status.Certificate,
+		matryoshkav1alpha1.DefaultAuthInfoClientKeyKey:         keyData,
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[csrGenerationAnnotation] = generation
+	if secret.ResourceVersion == "" {
+		if err := r.client.Create(ctx, secret); err != nil {
+			return nil, nil, fmt.Errorf("error persisting issued client certificate: %w", err)
+		}
+	} else if err := r.client.Update(ctx, secret); err != nil {
+		return nil, nil, fmt.Errorf("error persisting issued client certificate: %w", err)
+	}
+
+	return csrObj.Status.Certificate, keyData, nil
+}
+
+// nextCSRGeneration parses generation (the empty string counts as 0) and returns the next
+// generation's string form.
+func nextCSRGeneration(generation string) string {
+	n, _ := strconv.Atoi(generation)
+	return strconv.Itoa(n + 1)
+}
+
+// csrObjectName returns the cluster-scoped CertificateSigningRequest object name for the given
+// generation of namespace/secretName's certificate. CertificateSigningRequest has no namespace of
+// its own, so namespace is folded into the name - otherwise two Kubeconfigs in different
+// namespaces that happen to share a ClientCertificateRequest.Secret.Name would read and write the
+// same CSR object. namespace and secretName are each length-prefixed rather than simply joined
+// with "-": a bare "%s-%s-%s" join is ambiguous wherever namespace, secretName, or generation
+// itself contains a "-" (e.g. namespace "team-a", secretName "prod" collides with namespace
+// "team", secretName "a-prod"), which reintroduces the exact cross-tenant CSR/Secret collision
+// this encoding exists to prevent. Prefixing each variable-length component with its own length
+// makes the component boundaries unambiguous regardless of what characters they contain.
+// Generation "" or "0" (the first-ever request) omits the generation suffix; later generations
+// get a "-<generation>" suffix so a rotated request never collides with the CSR that issued the
+// certificate it's replacing.
+func csrObjectName(namespace, secretName, generation string) string {
+	base := fmt.Sprintf("%d-%s-%d-%s", len(namespace), namespace, len(secretName), secretName)
+	if generation == "" || generation == "0" {
+		return base
+	}
+	return fmt.Sprintf("%s-%s", base, generation)
+}
+
+func needsRenewal(certData []byte, renewBefore time.Duration) bool {
+	block, _ := pem.Decode(certData)
+	if block == nil {
+		return true
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return true
+	}
+
+	if renewBefore == 0 {
+		renewBefore = 24 * time.Hour
+	}
+
+	return time.Until(cert.NotAfter) < renewBefore
+}
+
+// ensurePrivateKey returns keyData unchanged if it already decodes as a PEM private key, or a
+// freshly generated one otherwise. Callers that are rotating an already-issued certificate should
+// call generatePrivateKey directly instead: reusing the old key across a rotation would carry
+// forward a potentially compromised key, defeating the point of rotating.
+func ensurePrivateKey(keyData []byte) ([]byte, error) {
+	if block, _ := pem.Decode(keyData); block != nil {
+		return keyData, nil
+	}
+
+	return generatePrivateKey()
+}
+
+func generatePrivateKey() ([]byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}), nil
+}
+
+func buildCertificateRequest(csr *matryoshkav1alpha1.ClientCertificateRequest, keyData []byte) ([]byte, error) {
+	block, _ := pem.Decode(keyData)
+	if block == nil {
+		return nil, fmt.Errorf("invalid private key PEM data")
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName:   csr.Subject.CommonName,
+			Organization: csr.Subject.Organizations,
+		},
+	}
+
+	requestBytes, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE REQUEST",
+		Bytes: requestBytes,
+	}), nil
+}
+
+func isApproved(csr *certificatesv1.CertificateSigningRequest) bool {
+	for _, cond := range csr.Status.Conditions {
+		if cond.Type == certificatesv1.CertificateApproved && cond.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isDenied reports whether csr has reached a terminal CertificateDenied or CertificateFailed
+// condition, i.e. an external approver or policy has rejected it and re-approving is not possible.
+func isDenied(csr *certificatesv1.CertificateSigningRequest) bool {
+	for _, cond := range csr.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		if cond.Type == certificatesv1.CertificateDenied || cond.Type == certificatesv1.CertificateFailed {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Resolver) resolveCluster(ctx context.Context, rs *ReferenceSet, namespace string, cluster *matryoshkav1alpha1.Cluster) (*clientcmdapiv1.Cluster, error) {
 	var certificateAuthorityData []byte
 	if certificateAuthority := cluster.CertificateAuthority; certificateAuthority != nil {
 		var err error
-		certificateAuthorityData, err = utils.GetSecretSelector(ctx, s, namespace, *certificateAuthority.Secret, matryoshkav1alpha1.DefaultClusterCertificateAuthorityKey)
+		certificateAuthorityData, err = getClusterSecretSelector(ctx, rs, namespace, certificateAuthority.Secret, matryoshkav1alpha1.DefaultClusterCertificateAuthorityKey)
 		if err != nil {
 			return nil, err
 		}
@@ -277,6 +911,9 @@ func (r *Resolver) resolveCluster(ctx context.Context, s *memorystore.Store, nam
 type ResolverOptions struct {
 	Client client.Client
 	Scheme *runtime.Scheme
+	// ClusterClientTTL controls how long a client built for a ClusterSecretSelector's ClusterRef
+	// is cached before the referenced Kubeconfig is re-resolved. Defaults to 5 minutes.
+	ClusterClientTTL time.Duration
 }
 
 func (o *ResolverOptions) Validate() error {
@@ -294,8 +931,12 @@ func NewResolver(opts ResolverOptions) (*Resolver, error) {
 		return nil, err
 	}
 
-	return &Resolver{
-		scheme: opts.Scheme,
-		client: opts.Client,
-	}, nil
+	r := &Resolver{
+		scheme:           opts.Scheme,
+		client:           opts.Client,
+		clusterClientTTL: opts.ClusterClientTTL,
+	}
+	r.clusterClients = newClusterClientCache(r)
+
+	return r, nil
 }
@@ -0,0 +1,309 @@
+// Copyright 2021 OnMetal authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubeconfig
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	matryoshkav1alpha1 "github.com/onmetal/matryoshka/apis/matryoshka/v1alpha1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func encodeCertificate(t *testing.T, notAfter time.Time) []byte {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestNeedsRenewal(t *testing.T) {
+	cases := []struct {
+		name        string
+		certData    []byte
+		renewBefore time.Duration
+		want        bool
+	}{
+		{
+			name:     "invalid pem data",
+			certData: []byte("not a certificate"),
+			want:     true,
+		},
+		{
+			name:        "far from expiry",
+			certData:    encodeCertificate(t, time.Now().Add(30*24*time.Hour)),
+			renewBefore: time.Hour,
+			want:        false,
+		},
+		{
+			name:        "within default renewal window",
+			certData:    encodeCertificate(t, time.Now().Add(time.Hour)),
+			renewBefore: 0,
+			want:        true,
+		},
+		{
+			name:        "within configured renewal window",
+			certData:    encodeCertificate(t, time.Now().Add(time.Hour)),
+			renewBefore: 2 * time.Hour,
+			want:        true,
+		},
+		{
+			name:        "already expired",
+			certData:    encodeCertificate(t, time.Now().Add(-time.Hour)),
+			renewBefore: time.Minute,
+			want:        true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := needsRenewal(tc.certData, tc.renewBefore); got != tc.want {
+				t.Errorf("needsRenewal() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEnsurePrivateKey(t *testing.T) {
+	t.Run("generates a key when none is given", func(t *testing.T) {
+		keyData, err := ensurePrivateKey(nil)
+		if err != nil {
+			t.Fatalf("ensurePrivateKey() error = %v", err)
+		}
+
+		block, _ := pem.Decode(keyData)
+		if block == nil {
+			t.Fatal("ensurePrivateKey() returned non-PEM data")
+		}
+		if _, err := x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			t.Fatalf("ensurePrivateKey() returned an unparseable key: %v", err)
+		}
+	})
+
+	t.Run("reuses an existing key", func(t *testing.T) {
+		existing, err := ensurePrivateKey(nil)
+		if err != nil {
+			t.Fatalf("ensurePrivateKey() error = %v", err)
+		}
+
+		got, err := ensurePrivateKey(existing)
+		if err != nil {
+			t.Fatalf("ensurePrivateKey() error = %v", err)
+		}
+		if string(got) != string(existing) {
+			t.Errorf("ensurePrivateKey() generated a new key instead of reusing the existing one")
+		}
+	})
+}
+
+func TestBuildCertificateRequest(t *testing.T) {
+	keyData, err := ensurePrivateKey(nil)
+	if err != nil {
+		t.Fatalf("ensurePrivateKey() error = %v", err)
+	}
+
+	csr := &matryoshkav1alpha1.ClientCertificateRequest{
+		Subject: matryoshkav1alpha1.CertificateSubject{
+			CommonName:    "system:node:test",
+			Organizations: []string{"system:nodes"},
+		},
+	}
+
+	requestBytes, err := buildCertificateRequest(csr, keyData)
+	if err != nil {
+		t.Fatalf("buildCertificateRequest() error = %v", err)
+	}
+
+	block, _ := pem.Decode(requestBytes)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		t.Fatalf("buildCertificateRequest() did not return a PEM-encoded certificate request")
+	}
+
+	req, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		t.Fatalf("buildCertificateRequest() returned an unparseable request: %v", err)
+	}
+	if req.Subject.CommonName != csr.Subject.CommonName {
+		t.Errorf("CommonName = %q, want %q", req.Subject.CommonName, csr.Subject.CommonName)
+	}
+	if len(req.Subject.Organization) != 1 || req.Subject.Organization[0] != "system:nodes" {
+		t.Errorf("Organization = %v, want [system:nodes]", req.Subject.Organization)
+	}
+}
+
+func TestIsApproved(t *testing.T) {
+	cases := []struct {
+		name string
+		csr  *certificatesv1.CertificateSigningRequest
+		want bool
+	}{
+		{
+			name: "no conditions",
+			csr:  &certificatesv1.CertificateSigningRequest{},
+			want: false,
+		},
+		{
+			name: "approved",
+			csr: &certificatesv1.CertificateSigningRequest{
+				Status: certificatesv1.CertificateSigningRequestStatus{
+					Conditions: []certificatesv1.CertificateSigningRequestCondition{
+						{Type: certificatesv1.CertificateApproved, Status: corev1.ConditionTrue},
+					},
+				},
+			},
+			want: true,
+		},
+		{
+			name: "denied",
+			csr: &certificatesv1.CertificateSigningRequest{
+				Status: certificatesv1.CertificateSigningRequestStatus{
+					Conditions: []certificatesv1.CertificateSigningRequestCondition{
+						{Type: certificatesv1.CertificateDenied, Status: corev1.ConditionTrue},
+					},
+				},
+			},
+			want: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isApproved(tc.csr); got != tc.want {
+				t.Errorf("isApproved() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCSRObjectName(t *testing.T) {
+	cases := []struct {
+		name       string
+		namespace  string
+		secretName string
+		generation string
+		want       string
+	}{
+		{name: "empty generation keeps the namespaced secret name", namespace: "ns-a", secretName: "node-cert", generation: "", want: "4-ns-a-9-node-cert"},
+		{name: "generation zero keeps the namespaced secret name", namespace: "ns-a", secretName: "node-cert", generation: "0", want: "4-ns-a-9-node-cert"},
+		{name: "later generation gets a suffix", namespace: "ns-a", secretName: "node-cert", generation: "2", want: "4-ns-a-9-node-cert-2"},
+		{name: "different namespaces with the same secret name don't collide", namespace: "ns-b", secretName: "node-cert", generation: "", want: "4-ns-b-9-node-cert"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := csrObjectName(tc.namespace, tc.secretName, tc.generation); got != tc.want {
+				t.Errorf("csrObjectName() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestCSRObjectNameDoesNotCollideAcrossBoundary ensures a naive "namespace-secretName" join's
+// ambiguity - where shifting a "-" across the namespace/secretName boundary produces the same
+// string - is closed by length-prefixing each component.
+func TestCSRObjectNameDoesNotCollideAcrossBoundary(t *testing.T) {
+	a := csrObjectName("team-a", "prod", "")
+	b := csrObjectName("team", "a-prod", "")
+	if a == b {
+		t.Fatalf("csrObjectName(%q, %q) and csrObjectName(%q, %q) collided on %q", "team-a", "prod", "team", "a-prod", a)
+	}
+}
+
+// encodeIDToken builds an unsigned JWT-shaped string with the given "exp" claim, good enough for
+// exercising idTokenExpiry, which only decodes the payload and never verifies the signature.
+func encodeIDToken(t *testing.T, exp time.Time) string {
+	t.Helper()
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	payload, err := json.Marshal(map[string]int64{"exp": exp.Unix()})
+	if err != nil {
+		t.Fatalf("error marshalling claims: %v", err)
+	}
+
+	return header + "." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+func TestIDTokenExpiry(t *testing.T) {
+	want := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	got, err := idTokenExpiry(encodeIDToken(t, want))
+	if err != nil {
+		t.Fatalf("idTokenExpiry() error = %v", err)
+	}
+	if !got.Equal(want) {
+		t.Errorf("idTokenExpiry() = %v, want %v", got, want)
+	}
+}
+
+func TestIDTokenExpiryRejectsMalformedTokens(t *testing.T) {
+	cases := []struct {
+		name    string
+		idToken string
+	}{
+		{name: "not a JWT", idToken: "not-a-jwt"},
+		{name: "payload is not valid base64", idToken: "header.not-base64!!!.sig"},
+		{name: "payload has no exp claim", idToken: "header." + base64.RawURLEncoding.EncodeToString([]byte(`{}`)) + ".sig"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := idTokenExpiry(tc.idToken); err == nil {
+				t.Fatal("idTokenExpiry() should error")
+			}
+		})
+	}
+}
+
+func TestNextCSRGeneration(t *testing.T) {
+	cases := []struct {
+		generation string
+		want       string
+	}{
+		{generation: "", want: "1"},
+		{generation: "0", want: "1"},
+		{generation: "4", want: "5"},
+	}
+
+	for _, tc := range cases {
+		if got := nextCSRGeneration(tc.generation); got != tc.want {
+			t.Errorf("nextCSRGeneration(%q) = %q, want %q", tc.generation, got, tc.want)
+		}
+	}
+}
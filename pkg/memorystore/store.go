@@ -0,0 +1,130 @@
+// Copyright 2021 OnMetal authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package memorystore provides an in-memory client.Client-like object store used to collect the
+// set of objects a Kubeconfig refers to before they are fetched from a real API server, so that
+// every reference can be resolved through a single batched get.
+package memorystore
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type objectKey struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+}
+
+// Store tracks a set of not-yet-fetched objects, keyed by GroupVersionKind/namespace/name. Create
+// registers an object reference; Objects returns every registered object so callers can fetch them
+// in a single batch; Get reads back a previously fetched object by key.
+type Store struct {
+	scheme *runtime.Scheme
+
+	mu      sync.Mutex
+	objects map[objectKey]client.Object
+}
+
+// New returns an empty Store that resolves GroupVersionKinds using scheme.
+func New(scheme *runtime.Scheme) *Store {
+	return &Store{
+		scheme:  scheme,
+		objects: map[objectKey]client.Object{},
+	}
+}
+
+func (s *Store) keyFor(obj client.Object) (objectKey, error) {
+	gvks, _, err := s.scheme.ObjectKinds(obj)
+	if err != nil {
+		return objectKey{}, fmt.Errorf("error determining object kind: %w", err)
+	}
+	if len(gvks) == 0 {
+		return objectKey{}, fmt.Errorf("no registered kind for object %T", obj)
+	}
+
+	return objectKey{gvk: gvks[0], namespace: obj.GetNamespace(), name: obj.GetName()}, nil
+}
+
+// Create registers obj as a reference to be resolved later. It returns an AlreadyExists error,
+// inspectable via apierrors.IsAlreadyExists, if the same GroupVersionKind/namespace/name was
+// already registered.
+func (s *Store) Create(_ context.Context, obj client.Object) error {
+	key, err := s.keyFor(obj)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.objects[key]; exists {
+		return apierrors.NewAlreadyExists(schema.GroupResource{Group: key.gvk.Group, Resource: key.gvk.Kind}, key.name)
+	}
+
+	s.objects[key] = obj
+	return nil
+}
+
+// Get copies the previously fetched object matching key and obj's kind into obj. It returns a
+// NotFound error, inspectable via apierrors.IsNotFound, if no such object was registered.
+func (s *Store) Get(_ context.Context, key client.ObjectKey, obj client.Object) error {
+	gvks, _, err := s.scheme.ObjectKinds(obj)
+	if err != nil {
+		return fmt.Errorf("error determining object kind: %w", err)
+	}
+	if len(gvks) == 0 {
+		return fmt.Errorf("no registered kind for object %T", obj)
+	}
+
+	s.mu.Lock()
+	stored, ok := s.objects[objectKey{gvk: gvks[0], namespace: key.Namespace, name: key.Name}]
+	s.mu.Unlock()
+	if !ok {
+		return apierrors.NewNotFound(schema.GroupResource{Group: gvks[0].Group, Resource: gvks[0].Kind}, key.Name)
+	}
+
+	storedValue := reflect.ValueOf(stored.DeepCopyObject())
+	objValue := reflect.ValueOf(obj)
+	if storedValue.Type() != objValue.Type() {
+		return fmt.Errorf("error copying stored object: stored %T, got %T", stored, obj)
+	}
+	objValue.Elem().Set(storedValue.Elem())
+
+	return nil
+}
+
+// Objects returns every object registered via Create, in the order used by the scheme-less
+// multigetter to issue the underlying batched get. Each returned pointer is the same one Create was
+// given, so a caller populating it in place (e.g. multigetter.MultiGet) makes the result visible
+// through subsequent Get calls.
+func (s *Store) Objects() []client.Object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	objects := make([]client.Object, 0, len(s.objects))
+	for _, obj := range s.objects {
+		objects = append(objects, obj)
+	}
+
+	return objects
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 OnMetal authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package multigetter batches the individual gets needed to populate a memorystore.Store's
+// references into a single concurrent round-trip against one API server.
+package multigetter
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Request is a single object to fetch: Key identifies it, Object receives the result.
+type Request struct {
+	Key    client.ObjectKey
+	Object client.Object
+}
+
+// RequestsFromObjects builds a Request for every object in objs, keyed by its own
+// namespace/name.
+func RequestsFromObjects(objs []client.Object) []Request {
+	requests := make([]Request, len(objs))
+	for i, obj := range objs {
+		requests[i] = Request{Key: client.ObjectKeyFromObject(obj), Object: obj}
+	}
+	return requests
+}
+
+// Options configures a MultiGetter.
+type Options struct {
+	// Client is used to perform the individual gets.
+	Client client.Client
+}
+
+func (o *Options) validate() error {
+	if o.Client == nil {
+		return fmt.Errorf("client needs to be set")
+	}
+	return nil
+}
+
+// MultiGetter fetches a batch of heterogeneous objects concurrently against a single client.
+type MultiGetter struct {
+	client client.Client
+}
+
+// New returns a MultiGetter for opts.
+func New(opts Options) (*MultiGetter, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	return &MultiGetter{client: opts.Client}, nil
+}
+
+// MultiGet fetches every request concurrently, populating each Request's Object in place. It
+// returns the first error encountered, after waiting for all in-flight gets to finish.
+func (g *MultiGetter) MultiGet(ctx context.Context, requests ...Request) error {
+	eg, ctx := errgroup.WithContext(ctx)
+
+	for _, req := range requests {
+		req := req
+		eg.Go(func() error {
+			if err := g.client.Get(ctx, req.Key, req.Object); err != nil {
+				return fmt.Errorf("error getting %T %s: %w", req.Object, req.Key, err)
+			}
+			return nil
+		})
+	}
+
+	return eg.Wait()
+}
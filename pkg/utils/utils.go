@@ -0,0 +1,82 @@
+// Copyright 2021 OnMetal authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package utils provides small helpers shared across matryoshka's resolvers.
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	matryoshkav1alpha1 "github.com/onmetal/matryoshka/apis/matryoshka/v1alpha1"
+	"github.com/onmetal/matryoshka/pkg/memorystore"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// IgnoreAlreadyExists returns nil if err is an AlreadyExists error, and err otherwise. It lets
+// callers register the same object reference (e.g. the same Secret referenced by two AuthInfo
+// fields) against a memorystore.Store more than once without having to track what they already
+// registered.
+func IgnoreAlreadyExists(err error) error {
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+// GetSecretSelector reads the key selected by selector (or defaultKey if selector.Key is unset)
+// out of the Secret selector.Name in namespace, fetching the Secret from s.
+func GetSecretSelector(ctx context.Context, s *memorystore.Store, namespace string, selector matryoshkav1alpha1.SecretSelector, defaultKey string) ([]byte, error) {
+	secret := &corev1.Secret{}
+	if err := s.Get(ctx, client.ObjectKey{Namespace: namespace, Name: selector.Name}, secret); err != nil {
+		return nil, fmt.Errorf("error getting secret %s/%s: %w", namespace, selector.Name, err)
+	}
+
+	key := selector.Key
+	if key == "" {
+		key = defaultKey
+	}
+
+	data, ok := secret.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s has no key %q", namespace, selector.Name, key)
+	}
+
+	return data, nil
+}
+
+// GetConfigMapSelector reads the key selected by selector (or defaultKey if selector.Key is unset)
+// out of the ConfigMap selector.Name in namespace, fetching the ConfigMap from s.
+func GetConfigMapSelector(ctx context.Context, s *memorystore.Store, namespace string, selector matryoshkav1alpha1.ConfigMapKeySelector, defaultKey string) ([]byte, error) {
+	configMap := &corev1.ConfigMap{}
+	if err := s.Get(ctx, client.ObjectKey{Namespace: namespace, Name: selector.Name}, configMap); err != nil {
+		return nil, fmt.Errorf("error getting configmap %s/%s: %w", namespace, selector.Name, err)
+	}
+
+	key := selector.Key
+	if key == "" {
+		key = defaultKey
+	}
+
+	if data, ok := configMap.BinaryData[key]; ok {
+		return data, nil
+	}
+	if data, ok := configMap.Data[key]; ok {
+		return []byte(data), nil
+	}
+
+	return nil, fmt.Errorf("configmap %s/%s has no key %q", namespace, selector.Name, key)
+}